@@ -0,0 +1,399 @@
+// go-rdbms の対話型シェル
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	rdbms "github.com/TonkyH/go-rdbms"
+)
+
+// バージョン情報（ビルド時に -ldflags "-X main.version=... -X main.commit=..." で注入）
+var (
+	version = "dev"
+	commit  = "unknown"
+)
+
+// メイン関数
+func main() {
+	showVersion := flag.Bool("version", false, "show version and build info")
+	memory := flag.Bool("memory", false, "use an in-memory database (nothing is written to disk)")
+	dir := flag.String("dir", "", "data directory (default ./db_mydb)")
+	verify := flag.Bool("verify", false, "check loaded data against the schema and report problems")
+	flag.Parse()
+
+	if *showVersion {
+		printVersion()
+		return
+	}
+
+	fmt.Println("Simple RDBMS - Type 'help' for commands")
+	fmt.Println("========================================")
+
+	// データベースを初期化または読み込み
+	var db *rdbms.Database
+	if *memory {
+		db = rdbms.NewInMemoryDatabase("mydb")
+		fmt.Println("In-memory database: changes are not saved")
+	} else {
+		var err error
+		if *dir != "" {
+			db, err = rdbms.LoadDatabaseAt("mydb", *dir)
+		} else {
+			db, err = rdbms.LoadDatabase("mydb")
+		}
+		if err != nil {
+			fmt.Printf("Failed to load database: %v\n", err)
+			return
+		}
+	}
+
+	// 読み込んだデータをスキーマと照合（問題があっても起動は続ける）
+	if *verify {
+		violations := db.VerifyIntegrity()
+		for _, v := range violations {
+			fmt.Printf("Warning: %v\n", v)
+		}
+		if len(violations) > 0 {
+			fmt.Printf("%d integrity problem(s) found; run CHECK DATABASE for details\n", len(violations))
+		}
+	}
+
+	runREPL(db, os.Stdin)
+}
+
+// 対話モード（入力の終端またはexit/quitで終了）
+func runREPL(db *rdbms.Database, in io.Reader) {
+	parser := rdbms.NewSQLParser(db)
+	scanner := bufio.NewScanner(in)
+	var last *rdbms.QueryResult         // 直前のSELECT結果（export用）
+	format := "table"                   // 結果の表示形式（\format で切り替え）
+	expanded := false                   // 拡張表示（\x で切り替え）
+	opts := rdbms.DefaultDisplayOptions // NULLの表示と区切り（\pset で変更）
+	opts.Timing = true                  // 文ごとの行数と実行時間（\timing で切り替え）
+
+	for {
+		fmt.Print("\nSQL> ")
+		if !scanner.Scan() {
+			break
+		}
+
+		query := strings.TrimSpace(scanner.Text())
+
+		// 特殊コマンド
+		switch strings.ToLower(query) {
+		case "exit", "quit":
+			if db.InTransaction() {
+				fmt.Println("Warning: uncommitted transaction discarded")
+			} else if db.HasUnsavedChanges() && !db.InMemory() {
+				fmt.Println("Warning: unsaved changes discarded (SET autosave = on to save)")
+			}
+			fmt.Println("Goodbye!")
+			return
+		case "help":
+			printHelp()
+			continue
+		case "tables":
+			showTables(db)
+			continue
+		case "\\version":
+			printVersion()
+			continue
+		case "\\x":
+			expanded = !expanded
+			if expanded {
+				fmt.Println("Expanded display is on")
+			} else {
+				fmt.Println("Expanded display is off")
+			}
+			continue
+		case "\\timing":
+			opts.Timing = !opts.Timing
+			if opts.Timing {
+				fmt.Println("Timing is on")
+			} else {
+				fmt.Println("Timing is off")
+			}
+			continue
+		case "":
+			continue
+		}
+
+		// 表示形式の切り替え: \format table|json
+		if fields := strings.Fields(query); len(fields) > 0 && strings.ToLower(fields[0]) == "\\format" {
+			format = setFormat(format, fields[1:])
+			continue
+		}
+
+		// 表示設定の変更: \pset null|separator 'value'
+		if fields := strings.Fields(query); len(fields) > 0 && strings.ToLower(fields[0]) == "\\pset" {
+			setDisplayOption(&opts, strings.TrimSpace(query[len(fields[0]):]))
+			continue
+		}
+
+		// SQLスクリプトの実行: source file.sql
+		if fields := strings.Fields(query); len(fields) > 0 && strings.ToLower(fields[0]) == "source" {
+			sourceScript(db, fields[1:])
+			continue
+		}
+
+		// 直前の結果をCSVに書き出す: export file.csv
+		if fields := strings.Fields(query); len(fields) > 0 && strings.ToLower(fields[0]) == "export" {
+			exportResult(last, fields[1:])
+			continue
+		}
+
+		// SQL実行（1行に複数の文がある場合は順に実行）
+		results, err := parser.ParseAll(query)
+		for _, result := range results {
+			result.Options = &opts
+			displayResult(result, format, expanded)
+			if result.Columns != nil {
+				last = result
+			}
+		}
+		if err != nil {
+			printError(err)
+		}
+	}
+}
+
+// エラー表示（構文エラーは問題の位置を ^ で示す）
+func printError(err error) {
+	fmt.Printf("Error: %v\n", err)
+	var syntaxErr *rdbms.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		if pointer := syntaxErr.Pointer(); pointer != "" {
+			fmt.Println(pointer)
+		}
+	}
+}
+
+// \formatコマンド（引数なしの場合は現在の形式を表示）
+func setFormat(current string, args []string) string {
+	if len(args) == 0 {
+		fmt.Printf("Output format: %s\n", current)
+		return current
+	}
+	switch format := strings.ToLower(args[0]); format {
+	case "table", "json":
+		fmt.Printf("Output format set to %s\n", format)
+		return format
+	default:
+		fmt.Println("Usage: \\format table|json")
+		return current
+	}
+}
+
+// \psetコマンド（値を省略すると現在の設定を表示。値の前後の引用符は取り除く）
+func setDisplayOption(opts *rdbms.DisplayOptions, args string) {
+	name, value, hasValue := strings.Cut(args, " ")
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+
+	var target *string
+	switch strings.ToLower(name) {
+	case "null":
+		target = &opts.Null
+	case "separator":
+		target = &opts.Separator
+	default:
+		fmt.Println("Usage: \\pset null|separator 'value'")
+		return
+	}
+
+	name = strings.ToLower(name)
+	if hasValue {
+		*target = value
+		fmt.Printf("%s set to '%s'\n", name, value)
+	} else {
+		fmt.Printf("%s is '%s'\n", name, *target)
+	}
+}
+
+// 結果の表示（JSON形式ではクエリ結果のみJSONで出力し、メッセージはそのまま表示）
+func displayResult(result *rdbms.QueryResult, format string, expanded bool) {
+	if format != "json" || result.Columns == nil || result.Message != "" || result.Error != nil {
+		if expanded {
+			result.DisplayExpanded()
+		} else {
+			result.Display()
+		}
+		return
+	}
+	if err := result.WriteJSON(os.Stdout); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if result.Options != nil && result.Options.Timing {
+		fmt.Println(result.Summary())
+	}
+	if result.Warning != "" {
+		fmt.Printf("Warning: %s\n", result.Warning)
+	}
+}
+
+// sourceコマンド
+func sourceScript(db *rdbms.Database, args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: source file.sql")
+		return
+	}
+
+	path := strings.Trim(args[0], `'"`)
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	defer file.Close()
+
+	if err := db.ExecScript(file); err != nil {
+		printError(fmt.Errorf("%s: %w", path, err))
+		return
+	}
+	fmt.Printf("Script '%s' executed\n", path)
+}
+
+// exportコマンド
+func exportResult(result *rdbms.QueryResult, args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: export file.csv")
+		return
+	}
+	if result == nil {
+		fmt.Println("Error: no query result to export")
+		return
+	}
+
+	path := strings.Trim(args[0], `'"`)
+	if err := result.WriteCSVFile(path); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("%d row(s) exported to '%s'\n", len(result.Rows), path)
+}
+
+// ヘルプ表示
+func printHelp() {
+	fmt.Print(`
+Commands:
+  CREATE TABLE table_name (column_name data_type [constraints], ...)
+  CREATE INDEX index_name ON table_name (column_name)
+  INSERT [OR REPLACE|OR IGNORE] INTO table_name [(columns)] VALUES (values), ... [RETURNING columns]
+  IMPORT 'file.csv' INTO table_name
+  SELECT [DISTINCT] column|expression [[AS] alias], ... FROM table_name [[AS] alias]
+         [WHERE condition] [GROUP BY column, ... | GROUP BY ROLLUP(column, ...)]
+         [ORDER BY column [ASC|DESC] [NULLS FIRST|LAST], ...]
+         [LIMIT n] [OFFSET m] [INTO OUTFILE 'file.csv']
+  UPDATE table_name SET column=value [FROM other_table] [WHERE condition] [RETURNING columns]
+  DELETE FROM table_name [WHERE condition] [RETURNING columns]
+  TRUNCATE TABLE table_name
+  REINDEX [TABLE table_name]
+  ALTER TABLE table_name ADD COLUMN column_name data_type [constraints]
+  ALTER TABLE table_name DROP COLUMN column_name
+  ALTER TABLE table_name MODIFY column_name data_type [constraints]
+  CHECK DATABASE
+  DUMP [TO 'file.sql']
+  BEGIN | START TRANSACTION
+  COMMIT
+  ROLLBACK
+  SET strict = on|off
+  SET ignore_case = on|off
+  SET max_result_rows = n
+  SET max_result_rows_action = error|truncate
+  SET statement_cache_size = n
+  SET storage_indent = on|off
+  SET autosave = on|off
+  SHOW TABLES
+  SHOW COLUMNS FROM table_name
+  SHOW CREATE TABLE table_name
+  COMMENT ON TABLE table_name IS 'text'
+  COMMENT ON COLUMN table_name.column_name IS 'text'
+  
+Special Commands:
+  tables    - Show all tables
+  export f  - Write the last query result to CSV file f
+  source f  - Run the SQL script in file f
+  \version  - Show version and build info
+  \format   - Set output format: \format table|json
+  \x        - Toggle expanded display (one line per column)
+  \timing   - Toggle the row count and elapsed time shown after each statement
+  \pset     - Set NULL text or column separator: \pset null|separator 'value'
+  help      - Show this help
+  exit/quit - Exit the program
+  
+Data Types:
+  INTEGER
+  VARCHAR(size)
+  BOOLEAN
+  FLOAT (REAL, DOUBLE)
+  DATE ('YYYY-MM-DD')
+  DATETIME ('YYYY-MM-DD HH:MM:SS', TIMESTAMP)
+  DECIMAL(precision, scale) (NUMERIC)
+  
+Aggregate Functions:
+  COUNT(*), COUNT(column), SUM(column), AVG(column), MIN(column), MAX(column)
+  
+Expressions:
+  column + 1, price * quantity, (a - b) / 2
+  UPPER(x), LOWER(x), LENGTH(x), ABS(x), ROUND(x[, n]), NULLIF(a, b)
+  date + INTERVAL '7 days', datetime - INTERVAL '3 hours' (days, hours, minutes)
+  SELECT 1 + 2 (without FROM: evaluate once and return one row)
+  
+Identifiers:
+  'text' is a string, "name" (or backquoted) is a table or column name
+  CREATE TABLE "order" ("select" INTEGER)
+  
+Constraints:
+  NOT NULL
+  PRIMARY KEY
+  PRIMARY KEY (column, ...)
+  UNIQUE
+  DEFAULT value
+  AUTO_INCREMENT
+  CHECK (condition)
+  FOREIGN KEY (column) REFERENCES table(column)
+  
+Examples:
+  CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(50) NOT NULL, age INTEGER);
+  INSERT INTO users VALUES (1, 'Alice', 25);
+  SELECT * FROM users WHERE age > 20;
+  UPDATE users SET age = 26 WHERE name = 'Alice';
+  DELETE FROM users WHERE id = 1;
+`)
+}
+
+// バージョン情報表示
+func printVersion() {
+	fmt.Printf("Simple RDBMS %s\n", version)
+	fmt.Printf("  commit:         %s\n", commit)
+	fmt.Printf("  go:             %s\n", runtime.Version())
+	fmt.Printf("  storage format: %d\n", rdbms.StorageFormatVersion)
+}
+
+// テーブル一覧表示
+func showTables(db *rdbms.Database) {
+	if len(db.Tables) == 0 {
+		fmt.Println("No tables found")
+		return
+	}
+
+	fmt.Println("Tables:")
+	for name, table := range db.Tables {
+		fmt.Printf("  %s (", name)
+		cols := []string{}
+		for _, col := range table.Columns {
+			cols = append(cols, col.Definition())
+		}
+		fmt.Printf("%s)\n", strings.Join(cols, ", "))
+	}
+}
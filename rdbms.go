@@ -0,0 +1,7424 @@
+// rdbms はJSONファイルにデータを保存するシンプルなRDBMSエンジン
+// 対話型シェルは cmd/go-rdbms にある
+package rdbms
+
+import (
+	"cmp"
+	"container/list"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// データディレクトリのストレージフォーマットバージョン
+const StorageFormatVersion = 1
+
+// データ型の定義
+type DataType string
+
+const (
+	TypeInteger  DataType = "INTEGER"
+	TypeVarchar  DataType = "VARCHAR"
+	TypeBoolean  DataType = "BOOLEAN"
+	TypeFloat    DataType = "FLOAT"
+	TypeDate     DataType = "DATE"
+	TypeDateTime DataType = "DATETIME"
+	TypeDecimal  DataType = "DECIMAL"
+)
+
+// サポートするデータ型か
+func (t DataType) valid() bool {
+	switch t {
+	case TypeInteger, TypeVarchar, TypeBoolean, TypeFloat, TypeDate, TypeDateTime, TypeDecimal:
+		return true
+	}
+	return false
+}
+
+// DECIMALの精度の上限（内部表現がint64のため）
+const maxDecimalPrecision = 18
+
+// DATE / DATETIMEの正規形（固定幅なので文字列のままでも時系列順に並ぶ）
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// カラム定義
+type Column struct {
+	Name          string      `json:"name"`
+	Type          DataType    `json:"type"`
+	Size          int         `json:"size,omitempty"`      // VARCHAR用
+	Precision     int         `json:"precision,omitempty"` // DECIMAL用（全体の桁数）
+	Scale         int         `json:"scale,omitempty"`     // DECIMAL用（小数点以下の桁数）
+	NotNull       bool        `json:"not_null"`
+	Primary       bool        `json:"primary"`
+	Unique        bool        `json:"unique,omitempty"`
+	Default       interface{} `json:"default,omitempty"`
+	Autoincrement bool        `json:"autoincrement,omitempty"`
+	Check         *WhereExpr  `json:"check,omitempty"` // CHECK制約（カラムがNULLの場合は評価しない）
+	Comment       string      `json:"comment,omitempty"`
+}
+
+// テーブル定義
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+	Rows    []Row    `json:"rows"`
+	Comment string   `json:"comment,omitempty"`
+	Indexes []*Index `json:"indexes,omitempty"`
+	// AUTO_INCREMENTで最後に採番した値
+	AutoIncrement int          `json:"auto_increment,omitempty"`
+	ForeignKeys   []ForeignKey `json:"foreign_keys,omitempty"`
+	// 複数カラムの主キー（PRIMARY KEY (a, b)）。各カラムはNOT NULLになる。単一カラムの主キーはColumn.Primary
+	PrimaryKey []string `json:"primary_key,omitempty"`
+
+	keys         map[string]*Index // 主キー・UNIQUEカラムの重複チェック用（保存せず読み込み時に構築する。nilの場合は次の参照時に構築する）
+	compositeKey map[string][]int  // 複数カラムの主キーの値（rowKey）から行位置を引く（keysと同時に構築する）
+}
+
+// 外部キー（参照先は主キーまたはUNIQUEカラム。参照されている行の削除は拒否する）
+type ForeignKey struct {
+	Column    string `json:"column"`
+	RefTable  string `json:"ref_table"`
+	RefColumn string `json:"ref_column"`
+}
+
+// インデックス（カラム値から行位置を引く。定義のみ保存し、内容は読み込み時に再構築する）
+type Index struct {
+	Name    string   `json:"name"`
+	Column  string   `json:"column"`
+	typ     DataType // カラムの型（キーの作り方を決める）
+	entries map[string][]int
+}
+
+// 行データ
+type Row map[string]interface{}
+
+// データベース
+type Database struct {
+	Name   string            `json:"name"`
+	Tables map[string]*Table `json:"tables"`
+	dbPath string            // 空の場合はメモリ上のみ（ファイルに保存しない）
+	strict bool              // 厳格な型チェックモード（SET strict = on）
+
+	maxResultRows   int  // SELECT結果の最大行数（0は無制限）
+	truncateResults bool // 最大行数超過時にエラーではなく切り詰める
+	indentJSON      bool // 保存するJSONを整形する（SET storage_indent = on）
+	ignoreCase      bool // テーブル名・カラム名の大文字・小文字を区別しない（SET ignore_case = on）
+	autoSave        bool // 変更のたびに保存する（SET autosave = off で明示的なSaveまで遅延）
+	unsaved         bool // autoSaveがoffの間に保存されていない変更がある
+
+	lastInsertID int // 直前のINSERTでAUTO_INCREMENTカラムに設定された値
+
+	schemaVersion int // テーブル定義を変更するたびに増える（文キャッシュの無効化用）
+
+	snapshot map[string]*Table // BEGIN時点のテーブル（トランザクション外ではnil）
+	txOwner  *SQLParser        // BEGINを実行したセッション（Database.Beginの場合はnil）
+
+	// 公開メソッドは読み取り系がRLock、変更系がLockを取るため、複数のgoroutineから同時に呼び出せる。
+	// トランザクションはDatabase全体で1つ。SQLParserごとのセッションのうち、BEGINしたセッション以外は
+	// 確定済み（BEGIN時点）のデータを読み、変更はエラーになる
+	mu   sync.RWMutex
+	txMu sync.Mutex // トランザクション中は開始したセッションが保持し、他のセッションの変更を排除する
+}
+
+// クエリ結果
+type QueryResult struct {
+	Columns  []string
+	Rows     []Row
+	Message  string
+	Warning  string
+	Error    error
+	Options  *DisplayOptions // Display / DisplayExpanded の表示設定（nilの場合はDefaultDisplayOptions）
+	Duration time.Duration   // 文の解析と実行にかかった時間（Parse / ParseArgsが設定する）
+}
+
+// 結果表示の設定
+type DisplayOptions struct {
+	Null      string // NULLの表示
+	Separator string // カラムの区切り
+	Timing    bool   // 行数の代わりに「(3 rows, 1.2ms)」の形で行数と実行時間を表示する
+}
+
+// 既定の表示設定
+var DefaultDisplayOptions = DisplayOptions{Null: "NULL", Separator: "|"}
+
+// 表示設定（未指定の場合は既定値）
+func (r *QueryResult) displayOptions() DisplayOptions {
+	if r.Options == nil {
+		return DefaultDisplayOptions
+	}
+	return *r.Options
+}
+
+// WHERE条件式（AND/ORの木構造。Opが空の場合はCondを持つ葉）
+type WhereExpr struct {
+	Op    string          `json:"op,omitempty"` // "AND", "OR"
+	Left  *WhereExpr      `json:"left,omitempty"`
+	Right *WhereExpr      `json:"right,omitempty"`
+	Cond  *WhereCondition `json:"cond,omitempty"`
+}
+
+// WHERE条件（単一の比較）
+type WhereCondition struct {
+	Column   string      `json:"column,omitempty"`
+	Columns  []string    `json:"columns,omitempty"` // タプル比較用（(a, b) > (1, 2)）。Valueは[]interface{}
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+	Escape   rune        `json:"escape,omitempty"` // LIKEのエスケープ文字（0は指定なし）
+	// 左辺がカラムではなく式（UPPER(name) = 'A'）。Columnは式の文字列表現
+	Expr *Expr `json:"expr,omitempty"`
+	// 右辺が値ではなくカラムの比較（a.x = b.y）。Valueは使わない
+	ValueColumn string `json:"value_column,omitempty"`
+	// 比較するカラムの型（coerceWhereが設定する。DATE/DATETIMEの場合だけ日時として比較する）
+	typ   DataType
+	types []DataType // タプル比較の要素ごとの型
+}
+
+// 値の式（算術式・関数呼び出し。OpもFuncも空の場合は、Columnが空でなければカラム、空ならValueのリテラル）
+type Expr struct {
+	Op     string      `json:"op,omitempty"` // "+", "-", "*", "/"
+	Left   *Expr       `json:"left,omitempty"`
+	Right  *Expr       `json:"right,omitempty"`
+	Func   string      `json:"func,omitempty"` // UPPER, LOWER, LENGTH, ABS, ROUND, NULLIF, INTERVAL
+	Args   []*Expr     `json:"args,omitempty"`
+	Column string      `json:"column,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+}
+
+// SELECT文
+type SelectQuery struct {
+	Table    string
+	Alias    string   // テーブルの別名（空の場合はテーブル名で修飾する）
+	Columns  []string // "*" は全カラム。式の場合はその文字列表現（結果のカラム名になる）
+	Exprs    []*Expr  // Columnsと同じ順の式（nilまたはnilの要素はカラム名・集約関数）
+	Aliases  []string // Columnsと同じ順の出力カラム名（空文字列は別名なし、nilは別名を使わない）
+	Distinct bool     // 重複行を除く（SELECT DISTINCT）
+	Where    *WhereExpr
+	GroupBy  []string
+	Rollup   bool // GROUP BY ROLLUP(...)：GroupByの末尾から順に外した小計と総計の行を加える
+	OrderBy  []OrderByItem
+	Limit    *int // nilは無制限
+	Offset   int
+}
+
+// 集約関数（SELECTリストの "COUNT(*)" などから生成）
+type Aggregate struct {
+	Func   string // COUNT, SUM, AVG, MIN, MAX
+	Column string // "*" はすべての行（COUNTのみ）
+}
+
+// ORDER BY項目
+type OrderByItem struct {
+	Column string
+	Desc   bool
+	Nulls  string // "FIRST" / "LAST"（空の場合、NULLは昇順で末尾・降順で先頭）
+}
+
+// SQLパーサー
+type SQLParser struct {
+	db    *Database
+	cache *statementCache
+}
+
+// 文キャッシュのデフォルトサイズ
+const defaultStatementCacheSize = 128
+
+// SQL文のトークン化結果とSELECT文の解析結果を保持するLRUキャッシュ
+type statementCache struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // 先頭が最近使われたエントリ
+	mu       sync.Mutex // 同じSQLParserを複数のgoroutineで共有できるようにする
+}
+
+type statementCacheEntry struct {
+	query  string
+	tokens []string
+	plan   *selectPlan // SELECT文の解析結果（未解析の場合はnil）
+}
+
+// 解析済みのSELECT文（プレースホルダの値は実行ごとに割り当てる）
+type selectPlan struct {
+	query         *SelectQuery // nilの場合は解析結果を再利用できない（毎回トークンから解析する）
+	outfile       string
+	placeholders  int
+	schemaVersion int // 解析時のスキーマの版（DDLで変わったら解析し直す）
+}
+
+// データベース初期化（データはカレントディレクトリの db_<name> に保存する）
+func NewDatabase(name string) *Database {
+	return NewDatabaseAt(name, defaultDatabaseDir(name))
+}
+
+// データディレクトリを指定したデータベース初期化
+func NewDatabaseAt(name, dir string) *Database {
+	os.MkdirAll(dir, 0755)
+
+	return &Database{
+		Name:     name,
+		Tables:   make(map[string]*Table),
+		dbPath:   dir,
+		autoSave: true,
+	}
+}
+
+// 既定のデータディレクトリ
+func defaultDatabaseDir(name string) string {
+	return fmt.Sprintf("./db_%s", name)
+}
+
+// メモリ上のみのデータベース（ファイルを一切読み書きしない。テストや一時的な用途向け）
+func NewInMemoryDatabase(name string) *Database {
+	return &Database{
+		Name:     name,
+		Tables:   make(map[string]*Table),
+		autoSave: true,
+	}
+}
+
+// データベース読み込み（カレントディレクトリの db_<name> から）
+func LoadDatabase(name string) (*Database, error) {
+	return LoadDatabaseAt(name, defaultDatabaseDir(name))
+}
+
+// データディレクトリを指定したデータベース読み込み（ディレクトリがなければ新規に作成する）
+func LoadDatabaseAt(name, dir string) (*Database, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("database directory must not be empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %v", err)
+	}
+	db := NewDatabaseAt(name, dir)
+
+	// メタデータファイルを読み込み
+	metaPath := filepath.Join(db.dbPath, "metadata.json")
+	if _, err := os.Stat(metaPath); os.IsNotExist(err) {
+		// 新規データベース
+		return db, nil
+	}
+
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// フォーマットバージョンの確認（未記録の場合は初期レイアウトのv0）
+	var header struct {
+		FormatVersion int `json:"format_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if header.FormatVersion > StorageFormatVersion {
+		return nil, fmt.Errorf("unsupported format version %d (supported up to %d)", header.FormatVersion, StorageFormatVersion)
+	}
+
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, err
+	}
+
+	// 各テーブルのデータを読み込み
+	for tableName, table := range db.Tables {
+		// データファイルがない（行を保存する前に中断した）テーブルは空とする。
+		// 読み込めないファイルは空のテーブルとして扱うと次の保存で上書きされるため、エラーにする
+		tablePath := filepath.Join(db.dbPath, fmt.Sprintf("%s.json", tableName))
+		data, err := os.ReadFile(tablePath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load table '%s': %v", tableName, err)
+		}
+		if err == nil {
+			var rows []Row
+			if err := json.Unmarshal(data, &rows); err != nil {
+				return nil, fmt.Errorf("failed to load table '%s': %v", tableName, err)
+			}
+			table.Rows = rows
+		}
+		table.restoreDecimals()
+	}
+	db.reindex("")
+
+	// 古いフォーマットの移行
+	if header.FormatVersion < StorageFormatVersion {
+		for v := header.FormatVersion; v < StorageFormatVersion; v++ {
+			if err := storageMigrations[v](db); err != nil {
+				return nil, fmt.Errorf("failed to migrate format version %d: %v", v, err)
+			}
+		}
+		if err := db.Save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// ストレージフォーマットの移行処理（キーは移行元のバージョン）
+var storageMigrations = map[int]func(db *Database) error{
+	// v0: format_versionを持たない初期レイアウト。
+	// 型名を検査せずに保存していたため、別名（REAL、TIMESTAMPなど）を正規の型名に直し、
+	// 型変換せずに保存されていた値をカラムの型に変換する。
+	// VARCHARのサイズ省略（Size = 0）は無制限として引き続き扱う
+	0: func(db *Database) error {
+		for name, table := range db.Tables {
+			for i, col := range table.Columns {
+				if canonical, ok := v0TypeAliases[col.Type]; ok {
+					table.Columns[i].Type = canonical
+				}
+				if table.Columns[i].Type == TypeDecimal && col.Precision == 0 {
+					table.Columns[i].Precision, table.Columns[i].Scale = v0DecimalPrecision(table.Rows, col.Name)
+				}
+			}
+			for i, row := range table.Rows {
+				for _, col := range table.Columns {
+					value := row[col.Name]
+					if value == nil {
+						continue
+					}
+					converted, err := validateAndConvertValue(value, col, false)
+					if err != nil {
+						return fmt.Errorf("table '%s' row %d column '%s': %v", name, i+1, col.Name, err)
+					}
+					row[col.Name] = converted
+				}
+			}
+			table.rebuildIndexes()
+		}
+		return nil
+	},
+}
+
+// v0の精度指定のないDECIMALの精度と位取り（保存されている値が収まるように決める）
+func v0DecimalPrecision(rows []Row, colName string) (int, int) {
+	digits, scale := 0, 0
+	for _, row := range rows {
+		if row[colName] == nil {
+			continue
+		}
+		text := strings.TrimLeft(formatValue(row[colName]), "+-")
+		whole, frac, _ := strings.Cut(text, ".")
+		digits = max(digits, len(whole))
+		scale = max(scale, len(frac))
+	}
+	precision := max(10, digits+scale)
+	return min(precision, maxDecimalPrecision), min(scale, maxDecimalPrecision)
+}
+
+// v0で型名のまま保存されていた別名
+var v0TypeAliases = map[DataType]DataType{
+	"REAL":      TypeFloat,
+	"DOUBLE":    TypeFloat,
+	"TIMESTAMP": TypeDateTime,
+	"NUMERIC":   TypeDecimal,
+}
+
+// データベース保存
+func (db *Database) Save() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.save()
+}
+
+// データベース保存（ロックは呼び出し側で取る）
+func (db *Database) save() error {
+	db.unsaved = false
+	if db.dbPath == "" {
+		return nil
+	}
+
+	// 各テーブルのデータを保存
+	for name, table := range db.Tables {
+		tablePath := filepath.Join(db.dbPath, fmt.Sprintf("%s.json", name))
+		data, err := db.marshalStorage(table.Rows)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(tablePath, data); err != nil {
+			return err
+		}
+	}
+
+	// メタデータは最後に保存する（書き込み途中のテーブルを参照させない）
+	metaPath := filepath.Join(db.dbPath, "metadata.json")
+	metaData, err := db.marshalStorage(map[string]interface{}{
+		"format_version": StorageFormatVersion,
+		"name":           db.Name,
+		"tables":         db.getTableMetadata(),
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, metaData)
+}
+
+// ファイルの置き換え（<path>.tmpに書き込んでからリネームするため、途中で中断しても元のファイルは壊れない）
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// 変更の永続化（トランザクション中はCOMMITまで、autoSaveがoffの場合は明示的なSaveまで保存しない）
+func (db *Database) persist() error {
+	if db.snapshot != nil {
+		return nil
+	}
+	if !db.autoSave {
+		db.unsaved = true
+		return nil
+	}
+	return db.save()
+}
+
+// 自動保存の切り替え（大量のINSERTをまとめて保存する場合はoffにしてから最後にSaveする）
+// onに戻したときに未保存の変更があれば保存する
+func (db *Database) SetAutoSave(on bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.autoSave = on
+	if on && db.unsaved && db.snapshot == nil {
+		return db.save()
+	}
+	return nil
+}
+
+// メモリ上のみのデータベースか
+func (db *Database) InMemory() bool {
+	return db.dbPath == ""
+}
+
+// 保存されていない変更があるか
+func (db *Database) HasUnsavedChanges() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.unsaved || db.snapshot != nil
+}
+
+// テーブル定義の版（文キャッシュの解析結果の無効化用）
+func (db *Database) currentSchemaVersion() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.schemaVersion
+}
+
+// ignore_caseが有効な場合、SQL文中のテーブル名・カラム名を定義どおりの表記に置き換える
+// カラム名は文中に現れるテーブルのカラムのみを対象とし、完全に一致する名前はそのまま使う
+func (db *Database) foldIdentifiers(tokens []string) []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if !db.ignoreCase {
+		return tokens
+	}
+
+	tableNames := make(map[string]string)
+	for _, name := range slices.Sorted(maps.Keys(db.Tables)) {
+		folded := strings.ToLower(name)
+		if _, exists := tableNames[folded]; !exists {
+			tableNames[folded] = name
+		}
+	}
+	foldTable := func(token string) string {
+		if _, exists := db.Tables[token]; exists {
+			return token
+		}
+		if name, exists := tableNames[strings.ToLower(token)]; exists {
+			return name
+		}
+		return token
+	}
+
+	folded := slices.Clone(tokens)
+	columnNames := make(map[string]string)
+	exactColumns := make(map[string]bool)
+	for i, token := range folded {
+		if strings.HasPrefix(token, "\x00") {
+			continue
+		}
+		folded[i] = foldTable(token)
+		if table, exists := db.Tables[folded[i]]; exists {
+			for _, col := range table.Columns {
+				exactColumns[col.Name] = true
+				if _, exists := columnNames[strings.ToLower(col.Name)]; !exists {
+					columnNames[strings.ToLower(col.Name)] = col.Name
+				}
+			}
+		}
+	}
+	foldColumn := func(token string) string {
+		if exactColumns[token] {
+			return token
+		}
+		if name, exists := columnNames[strings.ToLower(token)]; exists {
+			return name
+		}
+		return token
+	}
+
+	for i, token := range folded {
+		if strings.HasPrefix(token, "\x00") {
+			continue
+		}
+		if qualifiedColumnPattern.MatchString(token) {
+			qualifier, name, _ := strings.Cut(token, ".")
+			folded[i] = foldTable(qualifier) + "." + foldColumn(name)
+		} else if _, exists := db.Tables[token]; !exists {
+			folded[i] = foldColumn(token)
+		}
+	}
+	return folded
+}
+
+// トランザクション中か
+func (db *Database) InTransaction() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.snapshot != nil
+}
+
+// 他のセッションのトランザクション中の変更のエラー
+var errLockedByTransaction = fmt.Errorf("database is locked by a transaction in another session")
+
+// トランザクション開始（現在のテーブルのスナップショットを取る）
+func (db *Database) Begin() error {
+	return db.begin(nil)
+}
+
+// ownerのセッションでトランザクションを開始する
+func (db *Database) begin(owner *SQLParser) error {
+	if db.ownsTransaction(owner) {
+		return fmt.Errorf("transaction already in progress")
+	}
+	if err := db.lockSession(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.snapshot = make(map[string]*Table, len(db.Tables))
+	for name, table := range db.Tables {
+		db.snapshot[name] = table.clone()
+	}
+	db.txOwner = owner
+	return nil
+}
+
+// セッションの排他（他のセッションのトランザクション中はエラー、他のセッションの文の実行中は終わるまで待つ）
+func (db *Database) lockSession() error {
+	if db.txMu.TryLock() {
+		return nil
+	}
+	if db.InTransaction() {
+		return errLockedByTransaction
+	}
+	// 実行中の文が終わるまで待つ（その間に他のセッションがBEGINした場合はCOMMITまで待つ）
+	db.txMu.Lock()
+	return nil
+}
+
+// ownerのセッションが開いているトランザクションか
+func (db *Database) ownsTransaction(owner *SQLParser) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.snapshot != nil && db.txOwner == owner
+}
+
+// トランザクションの確定（ここで初めてディスクに書き込む）
+func (db *Database) Commit() error {
+	return db.endTransaction(nil, false)
+}
+
+// トランザクションの取り消し（BEGIN時点のテーブルに戻す）
+func (db *Database) Rollback() error {
+	return db.endTransaction(nil, true)
+}
+
+// ownerのセッションのトランザクションを確定または取り消す
+func (db *Database) endTransaction(owner *SQLParser, rollback bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.snapshot == nil {
+		return fmt.Errorf("no transaction in progress")
+	}
+	if db.txOwner != owner {
+		return errLockedByTransaction
+	}
+	defer db.txMu.Unlock()
+
+	if rollback {
+		db.Tables = db.snapshot
+		db.snapshot = nil
+		db.txOwner = nil
+		db.schemaVersion++
+		return nil
+	}
+	db.snapshot = nil
+	db.txOwner = nil
+	return db.save()
+}
+
+// テーブルの複製（行は値ごとコピーし、インデックスは複製先で再構築する）
+func (t *Table) clone() *Table {
+	c := *t
+	c.Columns = slices.Clone(t.Columns)
+	c.ForeignKeys = slices.Clone(t.ForeignKeys)
+	c.PrimaryKey = slices.Clone(t.PrimaryKey)
+	c.Rows = make([]Row, len(t.Rows))
+	for i, row := range t.Rows {
+		c.Rows[i] = maps.Clone(row)
+	}
+	c.Indexes = make([]*Index, len(t.Indexes))
+	for i, index := range t.Indexes {
+		c.Indexes[i] = &Index{Name: index.Name, Column: index.Column}
+	}
+	c.rebuildIndexes()
+	return &c
+}
+
+// 保存用のJSONエンコード（デフォルトはコンパクト形式）
+func (db *Database) marshalStorage(v interface{}) ([]byte, error) {
+	if db.indentJSON {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+// テーブルメタデータ取得
+func (db *Database) getTableMetadata() map[string]interface{} {
+	metadata := make(map[string]interface{})
+	for name, table := range db.Tables {
+		tableMeta := map[string]interface{}{
+			"name":    table.Name,
+			"columns": table.Columns,
+		}
+		if table.Comment != "" {
+			tableMeta["comment"] = table.Comment
+		}
+		if len(table.Indexes) > 0 {
+			tableMeta["indexes"] = table.Indexes
+		}
+		if table.AutoIncrement > 0 {
+			tableMeta["auto_increment"] = table.AutoIncrement
+		}
+		if len(table.ForeignKeys) > 0 {
+			tableMeta["foreign_keys"] = table.ForeignKeys
+		}
+		if len(table.PrimaryKey) > 0 {
+			tableMeta["primary_key"] = table.PrimaryKey
+		}
+		metadata[name] = tableMeta
+	}
+	return metadata
+}
+
+// CREATE TABLE実装
+func (db *Database) CreateTable(name string, columns []Column, foreignKeys ...ForeignKey) error {
+	return db.CreateTableWithPrimaryKey(name, columns, nil, foreignKeys...)
+}
+
+// 複数カラムの主キーを持つテーブルの作成（primaryKeyが空の場合はCreateTableと同じ）
+func (db *Database) CreateTableWithPrimaryKey(name string, columns []Column, primaryKey []string, foreignKeys ...ForeignKey) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, exists := db.Tables[name]; exists {
+		return fmt.Errorf("table '%s' already exists", name)
+	}
+
+	// プライマリキーチェック
+	primaryCount, autoincrementCount := 0, 0
+	for _, col := range columns {
+		if col.Primary {
+			primaryCount++
+		}
+		if col.Autoincrement {
+			autoincrementCount++
+		}
+	}
+	if primaryCount > 1 || (primaryCount > 0 && len(primaryKey) > 0) {
+		return fmt.Errorf("multiple primary keys defined")
+	}
+	if autoincrementCount > 1 {
+		return fmt.Errorf("multiple AUTO_INCREMENT columns defined")
+	}
+
+	// 複数カラムの主キー（1カラムだけの場合はそのカラムの主キーとして扱う）
+	for j, colName := range primaryKey {
+		index := slices.IndexFunc(columns, func(c Column) bool { return c.Name == colName })
+		if index < 0 {
+			return fmt.Errorf("primary key column '%s' does not exist", colName)
+		}
+		if slices.Contains(primaryKey[:j], colName) {
+			return fmt.Errorf("duplicate primary key column '%s'", colName)
+		}
+		if len(primaryKey) == 1 {
+			columns[index].Primary = true
+		} else {
+			columns[index].NotNull = true
+		}
+	}
+	if len(primaryKey) < 2 {
+		primaryKey = nil
+	}
+
+	// デフォルト値の検証
+	for i := range columns {
+		if err := db.validateColumn(&columns[i]); err != nil {
+			return err
+		}
+	}
+
+	table := &Table{
+		Name:        name,
+		Columns:     columns,
+		Rows:        []Row{},
+		ForeignKeys: foreignKeys,
+		PrimaryKey:  slices.Clone(primaryKey),
+	}
+
+	// 外部キーの検証（自己参照も可）
+	for _, fk := range foreignKeys {
+		if !table.hasColumn(fk.Column) {
+			return fmt.Errorf("foreign key column '%s' does not exist", fk.Column)
+		}
+		parent := db.Tables[fk.RefTable]
+		if fk.RefTable == name {
+			parent = table
+		}
+		if parent == nil {
+			return fmt.Errorf("referenced table '%s' does not exist", fk.RefTable)
+		}
+		ref := parent.getColumn(fk.RefColumn)
+		if ref == nil {
+			return fmt.Errorf("referenced column '%s' does not exist in table '%s'", fk.RefColumn, fk.RefTable)
+		}
+		if !ref.Primary && !ref.Unique {
+			return fmt.Errorf("referenced column %s(%s) must be PRIMARY KEY or UNIQUE", fk.RefTable, fk.RefColumn)
+		}
+	}
+
+	// CHECK制約が参照するカラムの検証
+	for i := range table.Columns {
+		if err := table.validateCheck(&table.Columns[i]); err != nil {
+			return err
+		}
+	}
+
+	db.Tables[name] = table
+
+	db.schemaVersion++
+	return db.persist()
+}
+
+// カラム定義の検証（未知のデータ型、不正なデフォルト値やAUTO_INCREMENTは定義時に拒否する）
+func (db *Database) validateColumn(col *Column) error {
+	if !col.Type.valid() {
+		return fmt.Errorf("unknown data type '%s' for column '%s'", col.Type, col.Name)
+	}
+	if col.Autoincrement && col.Type != TypeInteger {
+		return fmt.Errorf("AUTO_INCREMENT column '%s' must be INTEGER", col.Name)
+	}
+	if col.Default == nil {
+		return nil
+	}
+	converted, err := validateAndConvertValue(col.Default, *col, db.strict)
+	if err != nil {
+		return fmt.Errorf("invalid default for column '%s': %v", col.Name, err)
+	}
+	col.Default = converted
+	return nil
+}
+
+// テーブルのカラム名一覧（定義順）
+func (db *Database) columnNames(tableName string) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	names := make([]string, len(table.Columns))
+	for i, col := range table.Columns {
+		names[i] = col.Name
+	}
+	return names, nil
+}
+
+// テーブルにAUTO_INCREMENTカラムがあるか
+func (db *Database) hasAutoincrement(tableName string) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	table, exists := db.Tables[tableName]
+	return exists && table.autoincrementColumn() != nil
+}
+
+// 直前のINSERTでAUTO_INCREMENTカラムに設定された値
+func (db *Database) LastInsertID() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.lastInsertID
+}
+
+// INSERT実装
+func (db *Database) Insert(tableName string, values map[string]interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, err := db.insert(tableName, values); err != nil {
+		return err
+	}
+	return db.persist()
+}
+
+// INSERT ... RETURNING 実装（挿入した行のcolumnsを返す。"*"は全カラム）
+func (db *Database) InsertReturning(tableName string, values map[string]interface{}, columns []string) (*QueryResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	columns, err := db.returningColumns(tableName, columns)
+	if err != nil {
+		return nil, err
+	}
+	row, err := db.insert(tableName, values)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.persist(); err != nil {
+		return nil, err
+	}
+	return returningResult([]Row{row}, columns), nil
+}
+
+// INSERTで主キーが重複したときの動作
+type ConflictAction int
+
+const (
+	ConflictAbort   ConflictAction = iota // エラーにする（通常のINSERT）
+	ConflictReplace                       // 既存の行を新しい行で置き換える（INSERT OR REPLACE）
+	ConflictIgnore                        // 挿入せずに何もしない（INSERT OR IGNORE）
+)
+
+// 主キーが重複したときの動作を指定したINSERT（重複があった場合はtrueを返す）
+// 対象は主キーの重複のみで、UNIQUEカラムの重複は通常のINSERTと同じくエラーになる
+func (db *Database) InsertOr(tableName string, values map[string]interface{}, action ConflictAction) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, conflict, err := db.insertOr(tableName, values, action)
+	if err != nil {
+		return false, err
+	}
+	if conflict && action == ConflictIgnore {
+		return true, nil
+	}
+	return conflict, db.persist()
+}
+
+// 複数行のINSERT（1行でもエラーがあれば挿入前の状態に戻す）
+// 挿入した行数と、主キーが重複して置き換えた・無視した行数を返す
+func (db *Database) InsertRows(tableName string, rows []map[string]interface{}, action ConflictAction) (int, int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	written, conflicts, err := db.insertRows(tableName, rows, action)
+	if err != nil {
+		return 0, 0, err
+	}
+	inserted := len(written)
+	if action == ConflictReplace {
+		inserted -= conflicts
+	}
+	if len(written) == 0 {
+		return 0, conflicts, nil
+	}
+	return inserted, conflicts, db.persist()
+}
+
+// 複数行の INSERT ... RETURNING（挿入・置き換えた行のcolumnsを返す。"*"は全カラム）
+func (db *Database) InsertRowsReturning(tableName string, rows []map[string]interface{}, action ConflictAction, columns []string) (*QueryResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	columns, err := db.returningColumns(tableName, columns)
+	if err != nil {
+		return nil, err
+	}
+	written, _, err := db.insertRows(tableName, rows, action)
+	if err != nil {
+		return nil, err
+	}
+	if len(written) > 0 {
+		if err := db.persist(); err != nil {
+			return nil, err
+		}
+	}
+	return returningResult(written, columns), nil
+}
+
+// 複数行の挿入（ロック・保存は呼び出し側で行う）。1行でもエラーがあればテーブルを挿入前の状態に戻す
+// 挿入・置き換えた行をその順に返し、主キーが重複した行数も返す
+func (db *Database) insertRows(tableName string, rows []map[string]interface{}, action ConflictAction) ([]Row, int, error) {
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, 0, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	// 1行の挿入は失敗してもテーブルを変更しないため、複製は複数行の場合のみ
+	var backup *Table
+	lastInsertID := db.lastInsertID
+	if len(rows) > 1 {
+		backup = table.clone()
+	}
+
+	written := []Row{}
+	conflicts := 0
+	for i, values := range rows {
+		row, conflict, err := db.insertOr(tableName, values, action)
+		if err != nil {
+			if backup != nil {
+				db.Tables[tableName] = backup
+				db.lastInsertID = lastInsertID
+				err = fmt.Errorf("row %d: %v", i+1, err)
+			}
+			return nil, 0, err
+		}
+		if conflict {
+			conflicts++
+		}
+		if row != nil {
+			written = append(written, row)
+		}
+	}
+	return written, conflicts, nil
+}
+
+// 主キーが重複したときの動作を指定した1行の挿入（ロック・保存は呼び出し側で行う）
+// 挿入・置き換えた行（無視した場合はnil）と、主キーが重複したかを返す
+func (db *Database) insertOr(tableName string, values map[string]interface{}, action ConflictAction) (Row, bool, error) {
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, false, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if action != ConflictAbort {
+		row, err := db.newRow(table, values)
+		if err != nil {
+			return nil, false, err
+		}
+		if pos := table.primaryKeyRow(row); pos >= 0 {
+			if action == ConflictIgnore {
+				return nil, true, nil
+			}
+			// 既存の行を全カラム更新する（UNIQUE・CHECK・外部キーは更新と同じく検証する）
+			updated, err := db.updateRows(table, row, map[int]bool{pos: true})
+			if err != nil {
+				return nil, false, err
+			}
+			return updated[0], true, nil
+		}
+	}
+	row, err := db.insert(tableName, values)
+	return row, false, err
+}
+
+// 1行の挿入（ロック・保存は呼び出し側で行う）。挿入した行を返す
+func (db *Database) insert(tableName string, values map[string]interface{}) (Row, error) {
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	row, err := db.newRow(table, values)
+	if err != nil {
+		return nil, err
+	}
+
+	// CHECK制約
+	if err := table.checkConstraints(row); err != nil {
+		return nil, err
+	}
+
+	// プライマリキー・UNIQUEの重複チェック
+	for _, col := range table.Columns {
+		if err := table.checkUnique(col, row[col.Name], nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := table.checkPrimaryKey(row, nil); err != nil {
+		return nil, err
+	}
+
+	// 外部キーの参照先チェック（自分自身を参照する行は許可）
+	for _, fk := range table.ForeignKeys {
+		value := row[fk.Column]
+		if fk.RefTable == table.Name && valuesEqual(row[fk.RefColumn], value) {
+			continue
+		}
+		if err := db.checkReference(fk, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// AUTO_INCREMENTのカウンタを更新（手動で大きな値を挿入した場合も追従する）
+	if col := table.autoincrementColumn(); col != nil {
+		if id, ok := row[col.Name].(int); ok {
+			db.lastInsertID = id
+			table.AutoIncrement = max(table.AutoIncrement, id)
+		}
+	}
+
+	table.Rows = append(table.Rows, row)
+	for _, index := range table.Indexes {
+		index.add(row[index.Column], len(table.Rows)-1)
+	}
+	for _, index := range table.keys {
+		index.add(row[index.Column], len(table.Rows)-1)
+	}
+	if table.compositeKey != nil {
+		key := table.primaryKeyKey(row)
+		table.compositeKey[key] = append(table.compositeKey[key], len(table.Rows)-1)
+	}
+	return row, nil
+}
+
+// 挿入する行の作成（デフォルト値・AUTO_INCREMENTの採番・NOT NULL制約・型変換）
+func (db *Database) newRow(table *Table, values map[string]interface{}) (Row, error) {
+	// 存在しないカラムの値は無視せずエラーにする
+	for _, colName := range slices.Sorted(maps.Keys(values)) {
+		if !table.hasColumn(colName) {
+			return nil, fmt.Errorf("column '%s' does not exist", colName)
+		}
+	}
+
+	// データ型チェックと変換
+	row := make(Row)
+	for _, col := range table.Columns {
+		value, exists := values[col.Name]
+
+		// 省略されたカラムにはデフォルト値を使う
+		if !exists && col.Default != nil {
+			value, exists = col.Default, true
+		}
+
+		// AUTO_INCREMENTカラムが省略またはNULLの場合は次の値を採番
+		if col.Autoincrement && (!exists || value == nil) {
+			value, exists = table.AutoIncrement+1, true
+		}
+
+		// NOT NULL制約チェック
+		if col.NotNull && (!exists || value == nil) {
+			return nil, fmt.Errorf("column '%s' cannot be null", col.Name)
+		}
+
+		// データ型チェック
+		if exists && value != nil {
+			convertedValue, err := validateAndConvertValue(value, col, db.strict)
+			if err != nil {
+				return nil, fmt.Errorf("column '%s': %v", col.Name, err)
+			}
+			row[col.Name] = convertedValue
+		} else {
+			row[col.Name] = nil
+		}
+	}
+	return row, nil
+}
+
+// CSVの一括取り込み（1行目はヘッダー、空フィールドはNULL、ヘッダーにないカラムは省略扱い）
+// 1行でもエラーがあればテーブルを取り込み前の状態に戻す
+func (db *Database) ImportCSV(tableName string, r io.Reader) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return 0, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return 0, fmt.Errorf("empty CSV: missing header row")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// ヘッダーをカラムに対応付け
+	columns := make([]Column, len(header))
+	seen := make(map[string]bool)
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		col := table.getColumn(name)
+		if col == nil {
+			return 0, fmt.Errorf("column '%s' does not exist in table '%s'", name, tableName)
+		}
+		if seen[col.Name] {
+			return 0, fmt.Errorf("duplicate column '%s' in CSV header", col.Name)
+		}
+		seen[col.Name] = true
+		columns[i] = *col
+	}
+
+	backup := table.clone()
+	lastInsertID := db.lastInsertID
+	restore := func() {
+		db.Tables[tableName] = backup
+		db.lastInsertID = lastInsertID
+	}
+
+	count := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			restore()
+			return 0, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		values := make(map[string]interface{}, len(record))
+		for i, field := range record {
+			col := columns[i]
+			if field == "" {
+				values[col.Name] = nil
+				continue
+			}
+			value, err := validateAndConvertValue(field, col, false)
+			if err != nil {
+				restore()
+				return 0, fmt.Errorf("line %d: column '%s': %v", line, col.Name, err)
+			}
+			values[col.Name] = value
+		}
+
+		if _, err := db.insert(tableName, values); err != nil {
+			restore()
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+		count++
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+	return count, db.persist()
+}
+
+// CSVファイルからの一括取り込み
+func (db *Database) ImportCSVFile(path, tableName string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return db.ImportCSV(tableName, file)
+}
+
+// SELECT実装
+func (db *Database) Select(q *SelectQuery) (*QueryResult, error) {
+	return db.selectAs(nil, q)
+}
+
+// ownerのセッションとしてのSELECT（他のセッションのトランザクション中はBEGIN時点のデータを読む）
+// 実行順: WHEREで絞り込み → グループ化・集約 → ORDER BY → DISTINCT → OFFSET / LIMIT
+func (db *Database) selectAs(owner *SQLParser, q *SelectQuery) (*QueryResult, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	tables := db.Tables
+	if db.snapshot != nil && db.txOwner != owner {
+		tables = db.snapshot
+	}
+	table, exists := tables[q.Table]
+	if q.Table == "" {
+		// FROMのないSELECTは、カラムのない1行に対して式を評価する
+		table, exists = &Table{Rows: []Row{{}}}, true
+	}
+	if !exists {
+		return nil, fmt.Errorf("table '%s' does not exist", q.Table)
+	}
+
+	// 修飾されたカラム名（u.name、users.name）を解決し、WHERE条件の値をカラムの型に合わせる
+	q, err := q.resolveNames()
+	if err != nil {
+		return nil, err
+	}
+	if q.Where, err = table.coerceWhere(q.Where); err != nil {
+		return nil, err
+	}
+
+	// カラム検証
+	selectColumns := q.Columns
+	aggregates := make(map[string]*Aggregate)
+	exprs := make(map[string]*Expr)
+	if len(q.Columns) == 1 && q.Columns[0] == "*" {
+		selectColumns = []string{}
+		for _, col := range table.Columns {
+			selectColumns = append(selectColumns, col.Name)
+		}
+	} else {
+		for i, colName := range q.Columns {
+			if expr := q.expr(i); expr != nil {
+				for _, name := range expr.columns() {
+					if !table.hasColumn(name) {
+						return nil, fmt.Errorf("column '%s' does not exist", name)
+					}
+				}
+				exprs[colName] = expr
+				continue
+			}
+			if agg, ok := parseAggregate(colName); ok {
+				if err := agg.validate(table); err != nil {
+					return nil, err
+				}
+				aggregates[colName] = agg
+				continue
+			}
+			if !table.hasColumn(colName) {
+				return nil, fmt.Errorf("column '%s' does not exist", colName)
+			}
+		}
+	}
+	for _, colName := range q.GroupBy {
+		if !table.hasColumn(colName) {
+			return nil, fmt.Errorf("GROUP BY column '%s' does not exist", colName)
+		}
+	}
+	// ORDER BYの集約関数（SELECTリストにないものは並べ替え用に計算する）
+	for _, item := range q.OrderBy {
+		if agg, ok := parseAggregate(item.Column); ok {
+			if err := agg.validate(table); err != nil {
+				return nil, err
+			}
+			aggregates[item.Column] = agg
+			continue
+		}
+		if _, ok := exprs[item.Column]; !ok && !table.hasColumn(item.Column) {
+			return nil, fmt.Errorf("ORDER BY column '%s' does not exist", item.Column)
+		}
+	}
+	grouping := len(aggregates) > 0 || len(q.GroupBy) > 0
+
+	// 並べ替え・集約・DISTINCTがなければ結果は走査順に決まるため、
+	// OFFSETを除いて最大行数を1行超えた時点で走査を止める（超過の判定には1行あれば足りる）
+	scanLimit := -1
+	if db.maxResultRows > 0 && !grouping && len(q.OrderBy) == 0 && !q.Distinct {
+		scanLimit = q.Offset + db.maxResultRows + 1
+	}
+
+	// 行をフィルタリング
+	matched := []Row{}
+	for _, pos := range table.candidateRows(q.Where) {
+		if scanLimit >= 0 && len(matched) >= scanLimit {
+			break
+		}
+		row := table.Rows[pos]
+		if q.Where != nil {
+			match, err := evaluateWhere(row, q.Where)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+		matched = append(matched, row)
+	}
+
+	// 並べ替え（グループ化する場合は集約の後）。式の別名で並べ替える場合は行のコピーに式の値を加える
+	if len(q.OrderBy) > 0 && !grouping {
+		if slices.ContainsFunc(q.OrderBy, func(item OrderByItem) bool { return exprs[item.Column] != nil }) {
+			for k, row := range matched {
+				extended := maps.Clone(row)
+				for name, expr := range exprs {
+					value, err := evaluateExpr(row, expr)
+					if err != nil {
+						return nil, err
+					}
+					extended[name] = value
+				}
+				matched[k] = extended
+			}
+		}
+		sortRows(matched, q.OrderBy)
+	}
+
+	// 結果を作成
+	result := &QueryResult{
+		Columns: selectColumns,
+		Rows:    []Row{},
+	}
+
+	if grouping {
+		// グループ化して集約（式はGROUP BYのカラムのみ参照できる）
+		for _, col := range selectColumns {
+			if expr, ok := exprs[col]; ok {
+				for _, name := range expr.columns() {
+					if !slices.Contains(q.GroupBy, name) {
+						return nil, fmt.Errorf("column '%s' must appear in the GROUP BY clause or be used in an aggregate function", name)
+					}
+				}
+				continue
+			}
+			if _, ok := aggregates[col]; !ok && !slices.Contains(q.GroupBy, col) {
+				return nil, fmt.Errorf("column '%s' must appear in the GROUP BY clause or be used in an aggregate function", col)
+			}
+		}
+
+		// SELECTリストにないORDER BYの項目は並べ替えの後で取り除く
+		outputColumns := slices.Clone(selectColumns)
+		hidden := []string{}
+		for _, item := range q.OrderBy {
+			if slices.Contains(outputColumns, item.Column) {
+				continue
+			}
+			if _, ok := aggregates[item.Column]; !ok && !slices.Contains(q.GroupBy, item.Column) {
+				return nil, fmt.Errorf("ORDER BY column '%s' must appear in the GROUP BY clause or be used in an aggregate function", item.Column)
+			}
+			outputColumns = append(outputColumns, item.Column)
+			hidden = append(hidden, item.Column)
+		}
+
+		// keptは値を残すグループ化カラムの数（ROLLUPの小計・総計では外したカラムをNULLにする）
+		aggregateGroup := func(group []Row, kept int) error {
+			aggregatedRow := make(Row)
+			for _, col := range outputColumns {
+				if expr, ok := exprs[col]; ok {
+					source := Row{}
+					if len(group) > 0 {
+						source = maps.Clone(group[0])
+					}
+					for _, name := range q.GroupBy[kept:] {
+						source[name] = nil
+					}
+					value, err := evaluateExpr(source, expr)
+					if err != nil {
+						return err
+					}
+					aggregatedRow[col] = value
+					continue
+				}
+				agg, ok := aggregates[col]
+				if !ok {
+					if slices.Contains(q.GroupBy[kept:], col) {
+						aggregatedRow[col] = nil
+					} else {
+						aggregatedRow[col] = group[0][col]
+					}
+					continue
+				}
+				value, err := evaluateAggregate(agg, table.getColumn(agg.Column), group)
+				if err != nil {
+					return err
+				}
+				aggregatedRow[col] = value
+			}
+			result.Rows = append(result.Rows, aggregatedRow)
+			return nil
+		}
+
+		if q.Rollup {
+			// 各グループの行の後にその小計、最後に総計を出力する
+			var rollup func(rows []Row, depth int) error
+			rollup = func(rows []Row, depth int) error {
+				if depth < len(q.GroupBy) {
+					for _, group := range groupRows(rows, q.GroupBy[depth:depth+1]) {
+						if err := rollup(group, depth+1); err != nil {
+							return err
+						}
+					}
+				}
+				return aggregateGroup(rows, depth)
+			}
+			if err := rollup(matched, 0); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, group := range groupRows(matched, q.GroupBy) {
+				if err := aggregateGroup(group, len(q.GroupBy)); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if len(q.OrderBy) > 0 {
+			sortRows(result.Rows, q.OrderBy)
+		}
+		for _, row := range result.Rows {
+			for _, col := range hidden {
+				delete(row, col)
+			}
+		}
+	} else {
+		// 選択されたカラムのみを含む行を作成
+		for _, row := range matched {
+			selectedRow := make(Row)
+			for _, col := range selectColumns {
+				if expr, ok := exprs[col]; ok {
+					value, err := evaluateExpr(row, expr)
+					if err != nil {
+						return nil, err
+					}
+					selectedRow[col] = value
+					continue
+				}
+				selectedRow[col] = row[col]
+			}
+			result.Rows = append(result.Rows, selectedRow)
+		}
+	}
+
+	// DISTINCT（OFFSET / LIMITの前に適用）
+	if q.Distinct {
+		result.Rows = distinctRows(result.Rows, selectColumns)
+	}
+
+	// OFFSET / LIMIT（並べ替えの後に適用）
+	if q.Offset >= len(result.Rows) {
+		result.Rows = result.Rows[:0]
+	} else if q.Offset > 0 {
+		result.Rows = result.Rows[q.Offset:]
+	}
+	if q.Limit != nil && *q.Limit < len(result.Rows) {
+		result.Rows = result.Rows[:*q.Limit]
+	}
+
+	// 最大行数のチェック
+	if db.maxResultRows > 0 && len(result.Rows) > db.maxResultRows {
+		if !db.truncateResults {
+			return nil, fmt.Errorf("result exceeds max_result_rows (%d)", db.maxResultRows)
+		}
+		result.Rows = result.Rows[:db.maxResultRows]
+		result.Warning = fmt.Sprintf("result truncated to %d row(s) (max_result_rows)", db.maxResultRows)
+	}
+
+	// カラムの別名（結果のカラム名だけを置き換える）
+	if len(q.Aliases) > 0 {
+		if err := result.applyAliases(q.Aliases); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// 結果のカラム名を別名に置き換える（別名が他の出力カラムと重なる場合はエラー）
+func (r *QueryResult) applyAliases(aliases []string) error {
+	if len(aliases) != len(r.Columns) {
+		return fmt.Errorf("column aliases cannot be used with *")
+	}
+
+	names := make([]string, len(r.Columns))
+	for i, col := range r.Columns {
+		names[i] = cmp.Or(aliases[i], col)
+	}
+	for i, alias := range aliases {
+		for j, name := range names {
+			if alias != "" && j != i && name == alias {
+				return fmt.Errorf("duplicate column name '%s' in result", alias)
+			}
+		}
+	}
+
+	for i, row := range r.Rows {
+		renamed := make(Row, len(names))
+		for j, col := range r.Columns {
+			renamed[names[j]] = row[col]
+		}
+		r.Rows[i] = renamed
+	}
+	r.Columns = names
+	return nil
+}
+
+// GROUP BYカラムの値で行をグループ化（グループは最初に出現した順）
+// グループ化カラムがない場合は全行を1グループとする（行がなくても1グループ）
+func groupRows(rows []Row, groupBy []string) [][]Row {
+	if len(groupBy) == 0 {
+		return [][]Row{rows}
+	}
+
+	groups := [][]Row{}
+	index := make(map[string]int)
+	for _, row := range rows {
+		key := rowKey(row, groupBy, nil)
+		if i, exists := index[key]; exists {
+			groups[i] = append(groups[i], row)
+		} else {
+			index[key] = len(groups)
+			groups = append(groups, []Row{row})
+		}
+	}
+	return groups
+}
+
+// i番目のSELECTリストの式（カラム名・集約関数の場合はnil）
+func (q *SelectQuery) expr(i int) *Expr {
+	if i < len(q.Exprs) {
+		return q.Exprs[i]
+	}
+	return nil
+}
+
+// カラム名または集約関数（の引数）の修飾を解決する
+func (q *SelectQuery) resolveExpr(name string) (string, error) {
+	if agg, ok := parseAggregate(name); ok && agg.Column != "*" {
+		column, err := q.resolveColumn(agg.Column)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s(%s)", agg.Func, column), nil
+	}
+	return q.resolveColumn(name)
+}
+
+// テーブル名・別名で修飾されたカラム名を解決する（別名がある場合は別名でのみ修飾できる）
+func (q *SelectQuery) resolveColumn(name string) (string, error) {
+	qualifier, column, ok := strings.Cut(name, ".")
+	if !ok {
+		return name, nil
+	}
+	if (q.Alias != "" && qualifier != q.Alias) || (q.Alias == "" && qualifier != q.Table) {
+		return "", fmt.Errorf("unknown table or alias '%s' in '%s'", qualifier, name)
+	}
+	return column, nil
+}
+
+// カラム名を解決したクエリのコピーを返す（集約関数の引数・WHERE・GROUP BY・ORDER BYを含む）
+func (q *SelectQuery) resolveNames() (*SelectQuery, error) {
+	resolved := *q
+	resolved.Columns = make([]string, len(q.Columns))
+	if q.Exprs != nil {
+		resolved.Exprs = make([]*Expr, len(q.Exprs))
+	}
+	for i, name := range q.Columns {
+		// 式は結果のカラム名（書いたままの文字列）を変えずにカラム参照だけを解決する
+		if expr := q.expr(i); expr != nil {
+			mapped, err := expr.mapColumns(q.resolveColumn)
+			if err != nil {
+				return nil, err
+			}
+			resolved.Columns[i], resolved.Exprs[i] = name, mapped
+			continue
+		}
+		column, err := q.resolveExpr(name)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Columns[i] = column
+	}
+
+	resolved.GroupBy = make([]string, len(q.GroupBy))
+	for i, name := range q.GroupBy {
+		column, err := q.resolveColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		resolved.GroupBy[i] = column
+	}
+
+	resolved.OrderBy = make([]OrderByItem, len(q.OrderBy))
+	for i, item := range q.OrderBy {
+		// ORDER BYにはSELECTリストの別名（集約関数の別名を含む）も使える
+		if j := slices.Index(q.Aliases, item.Column); j >= 0 && item.Column != "" {
+			item.Column = q.Columns[j]
+			if q.expr(j) != nil {
+				resolved.OrderBy[i] = item
+				continue
+			}
+		}
+		column, err := q.resolveExpr(item.Column)
+		if err != nil {
+			return nil, err
+		}
+		item.Column = column
+		resolved.OrderBy[i] = item
+	}
+
+	if q.Where != nil {
+		where, err := q.Where.mapColumns(q.resolveColumn)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Where = where
+	}
+	return &resolved, nil
+}
+
+// 重複行の除去（DISTINCT。最初に現れた行を残すので並び順は保たれる）
+func distinctRows(rows []Row, columns []string) []Row {
+	seen := make(map[string]bool)
+	result := rows[:0]
+	for _, row := range rows {
+		key := rowKey(row, columns, nil)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// 指定カラムの値から行を識別するキーを作る（NULL同士は同じキー。typesはカラムの型で、結果の行はnil）
+func rowKey(row Row, columns []string, types []DataType) string {
+	keys := make([]string, len(columns))
+	for i, col := range columns {
+		var typ DataType
+		if types != nil {
+			typ = types[i]
+		}
+		if value := row[col]; value == nil {
+			keys[i] = "null"
+		} else {
+			keys[i] = valueKey(value, typ)
+		}
+	}
+	return strings.Join(keys, "\x00")
+}
+
+// 集約関数の解析（"COUNT(age)" -> {COUNT, age}）
+func parseAggregate(expr string) (*Aggregate, bool) {
+	open := strings.Index(expr, "(")
+	if open <= 0 || !strings.HasSuffix(expr, ")") {
+		return nil, false
+	}
+
+	name := strings.ToUpper(expr[:open])
+	if !aggregateFunctions[name] {
+		return nil, false
+	}
+	return &Aggregate{
+		Func:   name,
+		Column: expr[open+1 : len(expr)-1],
+	}, true
+}
+
+// 集約関数の名前
+var aggregateFunctions = map[string]bool{
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true,
+}
+
+// 集約関数の対象カラムの検証
+func (agg *Aggregate) validate(table *Table) error {
+	if agg.Column == "*" {
+		if agg.Func != "COUNT" {
+			return fmt.Errorf("%s(*) is not supported", agg.Func)
+		}
+		return nil
+	}
+
+	col := table.getColumn(agg.Column)
+	if col == nil {
+		return fmt.Errorf("column '%s' does not exist", agg.Column)
+	}
+	if (agg.Func == "SUM" || agg.Func == "AVG") && col.Type != TypeInteger && col.Type != TypeFloat && col.Type != TypeDecimal {
+		return fmt.Errorf("%s requires a numeric column, '%s' is %s", agg.Func, col.Name, col.Type)
+	}
+	return nil
+}
+
+// 集約関数の評価（NULLは無視する。対象行がない場合、COUNT以外はNULL）
+func evaluateAggregate(agg *Aggregate, col *Column, rows []Row) (interface{}, error) {
+	if agg.Func == "COUNT" && agg.Column == "*" {
+		return len(rows), nil
+	}
+
+	values := []interface{}{}
+	for _, row := range rows {
+		if value := row[agg.Column]; value != nil {
+			values = append(values, value)
+		}
+	}
+
+	switch agg.Func {
+	case "COUNT":
+		return len(values), nil
+
+	case "SUM", "AVG":
+		if len(values) == 0 {
+			return nil, nil
+		}
+		if col != nil && col.Type == TypeDecimal {
+			return sumDecimals(agg.Func, values, col.Scale)
+		}
+		if col != nil && col.Type == TypeInteger {
+			return sumIntegers(agg.Func, values)
+		}
+		sum := 0.0
+		for _, value := range values {
+			n, ok := toNumber(value)
+			if !ok {
+				return nil, fmt.Errorf("%s: non-numeric value %v", agg.Func, value)
+			}
+			sum += n
+		}
+		if agg.Func == "AVG" {
+			return sum / float64(len(values)), nil
+		}
+		return sum, nil
+
+	case "MIN", "MAX":
+		var result interface{}
+		for _, value := range values {
+			cmp := compareNullable(value, result)
+			if result == nil || (agg.Func == "MIN" && cmp < 0) || (agg.Func == "MAX" && cmp > 0) {
+				result = value
+			}
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("unknown aggregate function: %s", agg.Func)
+}
+
+// INTEGERカラムのSUM / AVG（合計はintで誤差なく計算し、範囲を超える場合はエラー。AVGの結果はFLOAT）
+func sumIntegers(fn string, values []interface{}) (interface{}, error) {
+	sum := 0
+	for _, value := range values {
+		n, ok := value.(int)
+		if f, isFloat := value.(float64); isFloat && f == math.Trunc(f) {
+			// JSONから読み込んだ整数値
+			n, ok = int(f), true
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s: non-integer value %v", fn, value)
+		}
+		next := sum + n
+		if (n > 0 && next < sum) || (n < 0 && next > sum) {
+			return nil, fmt.Errorf("%s: result out of range", fn)
+		}
+		sum = next
+	}
+	if fn == "AVG" {
+		return float64(sum) / float64(len(values)), nil
+	}
+	return sum, nil
+}
+
+// DECIMALカラムのSUM / AVG（結果はカラムのスケールを保つ）
+func sumDecimals(fn string, values []interface{}, scale int) (interface{}, error) {
+	sum := new(big.Int)
+	for _, value := range values {
+		d, ok := value.(Decimal)
+		if !ok || d.scale != scale {
+			return nil, fmt.Errorf("%s: non-decimal value %v", fn, value)
+		}
+		sum.Add(sum, big.NewInt(d.unscaled))
+	}
+	if fn == "AVG" {
+		sum = roundHalfAway(sum, big.NewInt(int64(len(values))))
+	}
+	if !sum.IsInt64() {
+		return nil, fmt.Errorf("%s: result out of range", fn)
+	}
+	return Decimal{unscaled: sum.Int64(), scale: scale}, nil
+}
+
+// ORDER BYによる安定ソート（NULLは昇順で末尾、降順で先頭）
+func sortRows(rows []Row, orderBy []OrderByItem) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, item := range orderBy {
+			a, b := rows[i][item.Column], rows[j][item.Column]
+			// NULLの位置はDESCで反転させず、NULLS FIRST / LAST に従う
+			if cmp, ok := compareNulls(a, b, item.nullsFirst()); ok {
+				if cmp == 0 {
+					continue
+				}
+				return cmp < 0
+			}
+			cmp := compareValues(a, b)
+			if cmp == 0 {
+				continue
+			}
+			if item.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+}
+
+// NULLを先頭に並べるか（NULLS FIRST / LAST の指定がなければNULLを最大値として扱う）
+func (item OrderByItem) nullsFirst() bool {
+	switch item.Nulls {
+	case "FIRST":
+		return true
+	case "LAST":
+		return false
+	}
+	return item.Desc
+}
+
+// 一意性のチェック（skipに含まれる行位置は比較対象外。NULLは重複してよい）
+func (t *Table) checkUnique(col Column, value interface{}, skip map[int]bool) error {
+	if value == nil || !(col.Primary || col.Unique) {
+		return nil
+	}
+	if index := t.keyIndex(col.Name); index != nil {
+		for _, i := range index.lookup(value) {
+			if !skip[i] {
+				return duplicateValueError(col, value)
+			}
+		}
+		return nil
+	}
+	for i, row := range t.Rows {
+		// JSONから読み込んだ値はfloat64になるため、型ではなく値で比較する
+		if !skip[i] && valuesEqual(row[col.Name], value) {
+			return duplicateValueError(col, value)
+		}
+	}
+	return nil
+}
+
+// rowと主キーの値が等しい既存の行の位置（主キーがない、またはNULLの場合は-1）
+func (t *Table) primaryKeyRow(row Row) int {
+	if len(t.PrimaryKey) > 0 {
+		if t.keys == nil {
+			t.buildKeys()
+		}
+		if positions := t.compositeKey[t.primaryKeyKey(row)]; len(positions) > 0 {
+			return positions[0]
+		}
+		return -1
+	}
+	for _, col := range t.Columns {
+		if value := row[col.Name]; col.Primary && value != nil {
+			if positions := t.keyIndex(col.Name).lookup(value); len(positions) > 0 {
+				return positions[0]
+			}
+		}
+	}
+	return -1
+}
+
+// 複数カラムの主キーの重複チェック（skipに含まれる行位置は比較対象外）
+func (t *Table) checkPrimaryKey(row Row, skip map[int]bool) error {
+	if len(t.PrimaryKey) == 0 {
+		return nil
+	}
+	if t.keys == nil {
+		t.buildKeys()
+	}
+	for _, i := range t.compositeKey[t.primaryKeyKey(row)] {
+		if !skip[i] {
+			return duplicateKeyError(row, t.PrimaryKey)
+		}
+	}
+	return nil
+}
+
+// 複数カラムの主キーの重複エラーの生成
+func duplicateKeyError(row Row, primaryKey []string) error {
+	return fmt.Errorf("duplicate primary key value: (%s)", formatKey(row, primaryKey))
+}
+
+// 複数カラムの値の表示（"1, 2"）
+func formatKey(row Row, columns []string) string {
+	values := make([]string, len(columns))
+	for i, col := range columns {
+		values[i] = fmt.Sprint(row[col])
+	}
+	return strings.Join(values, ", ")
+}
+
+// CHECK制約の評価
+func (t *Table) checkConstraints(row Row) error {
+	for _, col := range t.Columns {
+		if col.Check == nil || row[col.Name] == nil {
+			continue
+		}
+		ok, err := evaluateWhere(row, col.Check)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("CHECK constraint violated on column '%s': %s", col.Name, col.Check)
+		}
+	}
+	return nil
+}
+
+// CHECK制約が参照するカラムの存在チェック（デフォルト値が制約を満たすかも検証する）
+func (t *Table) validateCheck(col *Column) error {
+	if col.Check == nil {
+		return nil
+	}
+
+	// 対象カラムは新しい定義、それ以外はテーブルの定義で解決する
+	view := &Table{Columns: []Column{*col}}
+	for _, c := range t.Columns {
+		if c.Name != col.Name {
+			view.Columns = append(view.Columns, c)
+		}
+	}
+	for _, name := range col.Check.columns() {
+		if !view.hasColumn(name) {
+			return fmt.Errorf("CHECK constraint on column '%s' references unknown column '%s'", col.Name, name)
+		}
+	}
+
+	// 条件の値をカラムの型に合わせておく
+	check, err := view.coerceWhere(col.Check)
+	if err != nil {
+		return fmt.Errorf("CHECK constraint on column '%s': %v", col.Name, err)
+	}
+	col.Check = check
+
+	// 自身のカラムだけを参照する制約は、デフォルト値が満たすことを定義時に確かめる
+	if col.Default != nil && !slices.ContainsFunc(check.columns(), func(name string) bool { return name != col.Name }) {
+		ok, err := evaluateWhere(Row{col.Name: col.Default}, check)
+		if err != nil {
+			return fmt.Errorf("CHECK constraint on column '%s': %v", col.Name, err)
+		}
+		if !ok {
+			return fmt.Errorf("default value %s for column '%s' violates CHECK constraint: %s", quoteLiteral(col.Default, *col), col.Name, check)
+		}
+	}
+	return nil
+}
+
+// 重複エラーの生成
+func duplicateValueError(col Column, value interface{}) error {
+	if col.Primary {
+		return fmt.Errorf("duplicate primary key value: %v", value)
+	}
+	return fmt.Errorf("duplicate value %v for UNIQUE column '%s'", value, col.Name)
+}
+
+// UPDATE実装
+func (db *Database) Update(tableName string, updates map[string]interface{}, where *WhereExpr) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rows, err := db.update(tableName, updates, where)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.persist(); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// UPDATE ... RETURNING 実装（更新後の行のcolumnsを返す。"*"は全カラム）
+func (db *Database) UpdateReturning(tableName string, updates map[string]interface{}, where *WhereExpr, columns []string) (*QueryResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	columns, err := db.returningColumns(tableName, columns)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.update(tableName, updates, where)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.persist(); err != nil {
+		return nil, err
+	}
+	return returningResult(rows, columns), nil
+}
+
+// 行の更新（ロック・保存は呼び出し側で行う）。更新した行をテーブル内の順に返す
+func (db *Database) update(tableName string, updates map[string]interface{}, where *WhereExpr) ([]Row, error) {
+	table, err := db.updateTarget(tableName, updates)
+	if err != nil {
+		return nil, err
+	}
+
+	// WHERE条件の値をカラムの型に合わせる
+	if where, err = table.coerceWhere(where); err != nil {
+		return nil, err
+	}
+
+	// 更新対象の行を特定
+	matched := make(map[int]bool)
+	for _, i := range table.candidateRows(where) {
+		if where != nil {
+			match, err := evaluateWhere(table.Rows[i], where)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+		matched[i] = true
+	}
+
+	return db.updateRows(table, updates, matched)
+}
+
+// 更新対象のテーブルと更新する値の検証
+func (db *Database) updateTarget(tableName string, updates map[string]interface{}) (*Table, error) {
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	for colName, value := range updates {
+		col := table.getColumn(colName)
+		if col == nil {
+			return nil, fmt.Errorf("column '%s' does not exist", colName)
+		}
+
+		// データ型チェック
+		if value != nil {
+			_, err := validateAndConvertValue(value, *col, db.strict)
+			if err != nil {
+				return nil, fmt.Errorf("column '%s': %v", colName, err)
+			}
+		} else if col.NotNull {
+			return nil, fmt.Errorf("column '%s' cannot be null", colName)
+		}
+	}
+	return table, nil
+}
+
+// matchedの位置の行の更新（制約のチェック後に更新し、更新後の行を返す）
+func (db *Database) updateRows(table *Table, updates map[string]interface{}, matched map[int]bool) ([]Row, error) {
+	// プライマリキー・UNIQUEの重複チェック（更新対象の行自身は比較対象外）
+	for colName, value := range updates {
+		col := table.getColumn(colName)
+		if value == nil || !(col.Primary || col.Unique) || len(matched) == 0 {
+			continue
+		}
+		convertedValue, _ := validateAndConvertValue(value, *col, db.strict)
+		if len(matched) > 1 {
+			return nil, duplicateValueError(*col, convertedValue)
+		}
+		if err := table.checkUnique(*col, convertedValue, matched); err != nil {
+			return nil, err
+		}
+	}
+
+	// CHECK制約（更新後の行で評価）と複数カラムの主キーの重複チェック（更新後の行同士も比較する）
+	keyUpdated := slices.ContainsFunc(table.PrimaryKey, func(colName string) bool {
+		_, ok := updates[colName]
+		return ok
+	})
+	updatedKeys := make(map[string]bool)
+	for _, i := range slices.Sorted(maps.Keys(matched)) {
+		updated := maps.Clone(table.Rows[i])
+		for colName, value := range updates {
+			if value != nil {
+				value, _ = validateAndConvertValue(value, *table.getColumn(colName), db.strict)
+			}
+			updated[colName] = value
+		}
+		if err := table.checkConstraints(updated); err != nil {
+			return nil, err
+		}
+		if keyUpdated {
+			key := table.primaryKeyKey(updated)
+			if updatedKeys[key] {
+				return nil, duplicateKeyError(updated, table.PrimaryKey)
+			}
+			updatedKeys[key] = true
+			if err := table.checkPrimaryKey(updated, matched); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 外部キーのチェック（新しい値の参照先の存在と、変更される値が参照されていないこと）
+	for colName, value := range updates {
+		if value == nil || len(matched) == 0 {
+			continue
+		}
+		convertedValue, _ := validateAndConvertValue(value, *table.getColumn(colName), db.strict)
+		for _, fk := range table.ForeignKeys {
+			if fk.Column == colName {
+				if err := db.checkReference(fk, convertedValue); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for i := range matched {
+		for colName, value := range updates {
+			old := table.Rows[i][colName]
+			if value != nil {
+				value, _ = validateAndConvertValue(value, *table.getColumn(colName), db.strict)
+			}
+			if old != nil && !valuesEqual(old, value) {
+				if err := db.checkNotReferenced(table, colName, old, nil); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// 更新実行
+	updated := []Row{}
+	for _, i := range slices.Sorted(maps.Keys(matched)) {
+		// 行を更新
+		for colName, value := range updates {
+			col := table.getColumn(colName)
+			if value != nil {
+				convertedValue, _ := validateAndConvertValue(value, *col, db.strict)
+				table.Rows[i][colName] = convertedValue
+			} else {
+				table.Rows[i][colName] = nil
+			}
+		}
+		updated = append(updated, table.Rows[i])
+	}
+
+	if len(updated) > 0 {
+		table.rebuildIndexes()
+	}
+
+	return updated, nil
+}
+
+// UPDATE ... FROM 実装（sourceの行と組み合わせてWHERE条件に一致する行を更新する）
+// 条件のカラムは「テーブル名.カラム名」で修飾できる（両方のテーブルにあるカラムは修飾が必要）。
+// 複数のsourceの行に一致する行も1回だけ更新する
+func (db *Database) UpdateFrom(tableName string, updates map[string]interface{}, source string, where *WhereExpr) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rows, err := db.updateFrom(tableName, updates, source, where)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.persist(); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+func (db *Database) updateFrom(tableName string, updates map[string]interface{}, sourceName string, where *WhereExpr) ([]Row, error) {
+	table, err := db.updateTarget(tableName, updates)
+	if err != nil {
+		return nil, err
+	}
+	source, exists := db.Tables[sourceName]
+	if !exists {
+		return nil, fmt.Errorf("table '%s' does not exist", sourceName)
+	}
+	if sourceName == tableName {
+		return nil, fmt.Errorf("UPDATE ... FROM cannot join table '%s' with itself", tableName)
+	}
+
+	// 組み合わせた行のカラム名は「テーブル名.カラム名」
+	joined := &Table{}
+	for _, t := range []struct {
+		name  string
+		table *Table
+	}{{tableName, table}, {sourceName, source}} {
+		for _, col := range t.table.Columns {
+			col.Name = t.name + "." + col.Name
+			joined.Columns = append(joined.Columns, col)
+		}
+	}
+	if where != nil {
+		where, err = where.mapColumns(func(name string) (string, error) {
+			if strings.Contains(name, ".") {
+				if !joined.hasColumn(name) {
+					return "", fmt.Errorf("column '%s' does not exist", name)
+				}
+				return name, nil
+			}
+			inTarget, inSource := table.hasColumn(name), source.hasColumn(name)
+			switch {
+			case inTarget && inSource:
+				return "", fmt.Errorf("column '%s' is ambiguous: qualify it with '%s.' or '%s.'", name, tableName, sourceName)
+			case inTarget:
+				return tableName + "." + name, nil
+			case inSource:
+				return sourceName + "." + name, nil
+			}
+			return "", fmt.Errorf("column '%s' does not exist", name)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if where, err = joined.coerceWhere(where); err != nil {
+			return nil, err
+		}
+	}
+
+	// 更新対象の行を特定（一致するsourceの行が1つでもあれば更新する）
+	matched := make(map[int]bool)
+	for i, row := range table.Rows {
+		for _, sourceRow := range source.Rows {
+			combined := make(Row, len(joined.Columns))
+			for name, value := range row {
+				combined[tableName+"."+name] = value
+			}
+			for name, value := range sourceRow {
+				combined[sourceName+"."+name] = value
+			}
+			match := true
+			if where != nil {
+				if match, err = evaluateWhere(combined, where); err != nil {
+					return nil, err
+				}
+			}
+			if match {
+				matched[i] = true
+				break
+			}
+		}
+	}
+
+	return db.updateRows(table, updates, matched)
+}
+
+// DELETE実装
+func (db *Database) Delete(tableName string, where *WhereExpr) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rows, err := db.delete(tableName, where)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.persist(); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// DELETE ... RETURNING 実装（削除した行のcolumnsを返す。"*"は全カラム）
+func (db *Database) DeleteReturning(tableName string, where *WhereExpr, columns []string) (*QueryResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	columns, err := db.returningColumns(tableName, columns)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.delete(tableName, where)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.persist(); err != nil {
+		return nil, err
+	}
+	return returningResult(rows, columns), nil
+}
+
+// 行の削除（ロック・保存は呼び出し側で行う）。削除した行をテーブル内の順に返す
+func (db *Database) delete(tableName string, where *WhereExpr) ([]Row, error) {
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	// WHERE条件の値をカラムの型に合わせる
+	where, err := table.coerceWhere(where)
+	if err != nil {
+		return nil, err
+	}
+
+	// 削除対象の行を特定
+	deleted := make(map[int]bool)
+	for _, i := range table.candidateRows(where) {
+		shouldDelete := false
+
+		if where != nil {
+			match, err := evaluateWhere(table.Rows[i], where)
+			if err != nil {
+				return nil, err
+			}
+			shouldDelete = match
+		} else {
+			shouldDelete = true // WHERE句がない場合は全行削除
+		}
+
+		if shouldDelete {
+			deleted[i] = true
+		}
+	}
+
+	// 削除する行が他の行から参照されていないかチェック（RESTRICT）
+	if err := db.checkRowsNotReferenced(table, deleted); err != nil {
+		return nil, err
+	}
+
+	newRows := []Row{}
+	removed := []Row{}
+	for i, row := range table.Rows {
+		if deleted[i] {
+			removed = append(removed, row)
+		} else {
+			newRows = append(newRows, row)
+		}
+	}
+
+	table.Rows = newRows
+	if len(removed) > 0 {
+		table.rebuildIndexes()
+	}
+
+	return removed, nil
+}
+
+// RETURNINGのカラム名の検証（"*"はテーブルの全カラムに展開する）
+func (db *Database) returningColumns(tableName string, columns []string) ([]string, error) {
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	result := []string{}
+	for _, name := range columns {
+		if name == "*" {
+			for _, col := range table.Columns {
+				result = append(result, col.Name)
+			}
+			continue
+		}
+		col := table.getColumn(name)
+		if col == nil {
+			return nil, fmt.Errorf("column '%s' does not exist", name)
+		}
+		result = append(result, col.Name)
+	}
+	return result, nil
+}
+
+// RETURNINGの結果（行はテーブルと共有しないようコピーする）
+func returningResult(rows []Row, columns []string) *QueryResult {
+	result := &QueryResult{Columns: columns, Rows: []Row{}}
+	for _, row := range rows {
+		projected := make(Row, len(columns))
+		for _, col := range columns {
+			projected[col] = row[col]
+		}
+		result.Rows = append(result.Rows, projected)
+	}
+	return result
+}
+
+// テーブルコメント設定（空文字列で削除）
+func (db *Database) SetTableComment(tableName, comment string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	table.Comment = comment
+	return db.persist()
+}
+
+// カラムコメント設定（空文字列で削除）
+func (db *Database) SetColumnComment(tableName, colName, comment string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	col := table.getColumn(colName)
+	if col == nil {
+		return fmt.Errorf("column '%s' does not exist", colName)
+	}
+	col.Comment = comment
+	return db.persist()
+}
+
+// カラム追加（ALTER TABLE ... ADD COLUMN）
+// 既存の行には新しいカラムをNULLとして追加する
+func (db *Database) AddColumn(tableName string, col Column) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	if table.hasColumn(col.Name) {
+		return fmt.Errorf("column '%s' already exists", col.Name)
+	}
+	if col.Primary && len(table.PrimaryKey) > 0 {
+		return fmt.Errorf("multiple primary keys defined")
+	}
+	for _, c := range table.Columns {
+		if col.Primary && c.Primary {
+			return fmt.Errorf("multiple primary keys defined")
+		}
+		if col.Autoincrement && c.Autoincrement {
+			return fmt.Errorf("multiple AUTO_INCREMENT columns defined")
+		}
+	}
+	if err := db.validateColumn(&col); err != nil {
+		return err
+	}
+	if (col.Primary || (col.NotNull && col.Default == nil)) && len(table.Rows) > 0 {
+		return fmt.Errorf("cannot add NOT NULL column '%s' to a table with existing rows", col.Name)
+	}
+	if col.Unique && col.Default != nil && len(table.Rows) > 1 {
+		return duplicateValueError(col, col.Default)
+	}
+	if err := table.validateCheck(&col); err != nil {
+		return err
+	}
+	if col.Check != nil && col.Default != nil {
+		for _, row := range table.Rows {
+			added := maps.Clone(row)
+			added[col.Name] = col.Default
+			ok, err := evaluateWhere(added, col.Check)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("CHECK constraint violated on column '%s': %s", col.Name, col.Check)
+			}
+		}
+	}
+
+	// 既存の行にはデフォルト値（なければNULL）を設定
+	table.Columns = append(table.Columns, col)
+	for _, row := range table.Rows {
+		row[col.Name] = col.Default
+	}
+	table.keys = nil
+
+	db.schemaVersion++
+	return db.persist()
+}
+
+// カラム削除（ALTER TABLE ... DROP COLUMN）
+func (db *Database) DropColumn(tableName, colName string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	col := table.getColumn(colName)
+	if col == nil {
+		return fmt.Errorf("column '%s' does not exist", colName)
+	}
+	if col.Primary || slices.Contains(table.PrimaryKey, colName) {
+		return fmt.Errorf("cannot drop primary key column '%s'", colName)
+	}
+	if len(table.Columns) == 1 {
+		return fmt.Errorf("cannot drop the only column of table '%s'", tableName)
+	}
+	for _, child := range db.Tables {
+		for _, fk := range child.ForeignKeys {
+			if fk.RefTable == tableName && fk.RefColumn == colName {
+				return fmt.Errorf("cannot drop column '%s': referenced by a foreign key in table '%s'", colName, child.Name)
+			}
+		}
+	}
+
+	table.Columns = slices.DeleteFunc(table.Columns, func(c Column) bool {
+		return c.Name == colName
+	})
+	for _, row := range table.Rows {
+		delete(row, colName)
+	}
+	table.Indexes = slices.DeleteFunc(table.Indexes, func(index *Index) bool {
+		return index.Column == colName
+	})
+	table.ForeignKeys = slices.DeleteFunc(table.ForeignKeys, func(fk ForeignKey) bool {
+		return fk.Column == colName
+	})
+	table.keys = nil
+
+	db.schemaVersion++
+	return db.persist()
+}
+
+// カラム定義の変更（ALTER TABLE ... MODIFY）
+// 拡張（VARCHARのサイズ拡大など）の場合はデータに触れず、それ以外は全行を新しい定義で検証・変換する
+func (db *Database) ModifyColumn(tableName string, col Column) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	index := -1
+	for i, c := range table.Columns {
+		if c.Name == col.Name {
+			index = i
+		} else if col.Primary && c.Primary {
+			return fmt.Errorf("multiple primary keys defined")
+		} else if col.Autoincrement && c.Autoincrement {
+			return fmt.Errorf("multiple AUTO_INCREMENT columns defined")
+		}
+	}
+	if index == -1 {
+		return fmt.Errorf("column '%s' does not exist", col.Name)
+	}
+	if col.Primary && len(table.PrimaryKey) > 0 {
+		return fmt.Errorf("multiple primary keys defined")
+	}
+
+	old := table.Columns[index]
+	if col.Comment == "" {
+		col.Comment = old.Comment
+	}
+	// 主キーはMODIFYで指定しなくても維持する（主キーを外す構文はない）
+	if old.Primary {
+		col.Primary = true
+	}
+	if slices.Contains(table.PrimaryKey, col.Name) {
+		col.NotNull = true
+	}
+	if err := db.validateColumn(&col); err != nil {
+		return err
+	}
+
+	// 外部キーで結ばれたカラムは型を変えられない（参照元と参照先の型がずれる）
+	if col.Type != old.Type {
+		for _, fk := range table.ForeignKeys {
+			if fk.Column == col.Name {
+				return fmt.Errorf("cannot change type of column '%s': it has a foreign key to %s(%s)", col.Name, fk.RefTable, fk.RefColumn)
+			}
+		}
+		for _, child := range db.Tables {
+			for _, fk := range child.ForeignKeys {
+				if fk.RefTable == tableName && fk.RefColumn == col.Name {
+					return fmt.Errorf("cannot change type of column '%s': referenced by a foreign key in table '%s'", col.Name, child.Name)
+				}
+			}
+		}
+	}
+
+	// 既存データの検証
+	converted := make([]interface{}, len(table.Rows))
+	for i, row := range table.Rows {
+		value := row[col.Name]
+		if value == nil {
+			if col.NotNull || col.Primary {
+				return fmt.Errorf("row %d: column '%s' cannot be null", i+1, col.Name)
+			}
+			continue
+		}
+		convertedValue, err := validateAndConvertValue(value, col, db.strict)
+		if err != nil {
+			return fmt.Errorf("row %d: column '%s': %v", i+1, col.Name, err)
+		}
+		converted[i] = convertedValue
+	}
+
+	// CHECK制約
+	if err := table.validateCheck(&col); err != nil {
+		return err
+	}
+	if col.Check != nil {
+		for i, row := range table.Rows {
+			if converted[i] == nil {
+				continue
+			}
+			modified := maps.Clone(row)
+			modified[col.Name] = converted[i]
+			ok, err := evaluateWhere(modified, col.Check)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("row %d: CHECK constraint violated on column '%s': %s", i+1, col.Name, col.Check)
+			}
+		}
+	}
+
+	// 新たに主キー・UNIQUEになる場合は重複チェック（NULLは対象外）
+	if (col.Primary && !old.Primary) || (col.Unique && !old.Unique) {
+		for i := range converted {
+			if converted[i] == nil {
+				continue
+			}
+			for j := 0; j < i; j++ {
+				if converted[j] != nil && compareValues(converted[i], converted[j]) == 0 {
+					return duplicateValueError(col, converted[i])
+				}
+			}
+		}
+	}
+
+	if !isWideningChange(old, col) {
+		for i, row := range table.Rows {
+			row[col.Name] = converted[i]
+		}
+		table.rebuildIndexes()
+	}
+
+	// 新たにAUTO_INCREMENTになる場合は既存の最大値から採番を続ける
+	if col.Autoincrement && !old.Autoincrement {
+		for _, value := range converted {
+			if id, ok := value.(int); ok {
+				table.AutoIncrement = max(table.AutoIncrement, id)
+			}
+		}
+	}
+
+	table.Columns[index] = col
+	table.keys = nil
+	db.schemaVersion++
+	return db.persist()
+}
+
+// 既存データを変換せずに適用できるカラム定義の変更か
+func isWideningChange(old, col Column) bool {
+	if old.Type != col.Type {
+		return false
+	}
+	if col.Type == TypeVarchar {
+		return col.Size == 0 || (old.Size > 0 && col.Size >= old.Size)
+	}
+	if col.Type == TypeDecimal {
+		return col.Scale == old.Scale && col.Precision >= old.Precision
+	}
+	return true
+}
+
+// SHOW TABLES実装
+func (db *Database) ShowTables() *QueryResult {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	names := []string{}
+	for name := range db.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := &QueryResult{
+		Columns: []string{"table", "comment"},
+		Rows:    []Row{},
+	}
+	for _, name := range names {
+		result.Rows = append(result.Rows, Row{
+			"table":   name,
+			"comment": nullIfEmpty(db.Tables[name].Comment),
+		})
+	}
+	return result
+}
+
+// SHOW COLUMNS実装
+func (db *Database) ShowColumns(tableName string) (*QueryResult, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	result := &QueryResult{
+		Columns: []string{"name", "type", "size", "not_null", "primary", "unique", "default", "comment"},
+		Rows:    []Row{},
+	}
+	for _, col := range table.Columns {
+		var size interface{}
+		if col.Size > 0 {
+			size = col.Size
+		}
+		result.Rows = append(result.Rows, Row{
+			"name":     col.Name,
+			"type":     string(col.Type),
+			"size":     size,
+			"not_null": col.NotNull,
+			"primary":  col.Primary || slices.Contains(table.PrimaryKey, col.Name),
+			"unique":   col.Unique,
+			"default":  col.Default,
+			"comment":  nullIfEmpty(col.Comment),
+		})
+	}
+	return result, nil
+}
+
+// SHOW CREATE TABLE実装（テーブルを再作成するSQLを返す）
+func (db *Database) ShowCreateTable(tableName string) (*QueryResult, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	return &QueryResult{
+		Columns: []string{"table", "create_statement"},
+		Rows: []Row{{
+			"table":            table.Name,
+			"create_statement": table.createStatement(),
+		}},
+	}, nil
+}
+
+// データベース全体をSQLスクリプトとして出力（Parseで1行ずつ実行すると再作成できる）
+// 外部キーの参照先テーブルを先に作成し、各テーブルのCREATE文の後に全行のINSERT文を続ける
+func (db *Database) Dump(w io.Writer) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, table := range db.dumpOrder() {
+		if _, err := fmt.Fprintln(w, table.createStatement()); err != nil {
+			return err
+		}
+
+		names := make([]string, len(table.Columns))
+		for i, col := range table.Columns {
+			names[i] = quoteIdentifier(col.Name)
+		}
+		for _, row := range table.Rows {
+			values := make([]string, len(table.Columns))
+			for i, col := range table.Columns {
+				values[i] = quoteLiteral(row[col.Name], col)
+			}
+			if _, err := fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES (%s);\n",
+				quoteIdentifier(table.Name), strings.Join(names, ", "), strings.Join(values, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ダンプをファイルに出力
+func (db *Database) DumpFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Dump(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ダンプするテーブルの順序（名前順を基本に、外部キーの参照先を先にする）
+func (db *Database) dumpOrder() []*Table {
+	names := slices.Sorted(maps.Keys(db.Tables))
+
+	order := []*Table{}
+	visited := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		table, exists := db.Tables[name]
+		if !exists || visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, fk := range table.ForeignKeys {
+			visit(fk.RefTable)
+		}
+		order = append(order, table)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return order
+}
+
+// CREATE TABLE文とCOMMENT ON文、CREATE INDEX文の生成
+func (t *Table) createStatement() string {
+	defs := []string{}
+	for _, col := range t.Columns {
+		defs = append(defs, col.Definition())
+	}
+	if len(t.PrimaryKey) > 0 {
+		keys := make([]string, len(t.PrimaryKey))
+		for i, name := range t.PrimaryKey {
+			keys[i] = quoteIdentifier(name)
+		}
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keys, ", ")))
+	}
+	for _, fk := range t.ForeignKeys {
+		defs = append(defs, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s)",
+			quoteIdentifier(fk.Column), quoteIdentifier(fk.RefTable), quoteIdentifier(fk.RefColumn)))
+	}
+	name := quoteIdentifier(t.Name)
+	stmts := []string{fmt.Sprintf("CREATE TABLE %s (%s);", name, strings.Join(defs, ", "))}
+
+	commentCol := Column{Type: TypeVarchar}
+	if t.Comment != "" {
+		stmts = append(stmts, fmt.Sprintf("COMMENT ON TABLE %s IS %s;", name, quoteLiteral(t.Comment, commentCol)))
+	}
+	for _, col := range t.Columns {
+		if col.Comment != "" {
+			stmts = append(stmts, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;", name, quoteIdentifier(col.Name), quoteLiteral(col.Comment, commentCol)))
+		}
+	}
+	for _, index := range t.Indexes {
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX %s ON %s (%s);", quoteIdentifier(index.Name), name, quoteIdentifier(index.Column)))
+	}
+
+	return strings.Join(stmts, "\n")
+}
+
+// カラム定義のSQL表現
+func (col Column) Definition() string {
+	def := fmt.Sprintf("%s %s", quoteIdentifier(col.Name), col.Type)
+	if col.Type == TypeVarchar && col.Size > 0 {
+		def += fmt.Sprintf("(%d)", col.Size)
+	}
+	if col.Type == TypeDecimal {
+		def += fmt.Sprintf("(%d,%d)", col.Precision, col.Scale)
+	}
+	if col.Primary {
+		def += " PRIMARY KEY"
+	}
+	if col.Autoincrement {
+		def += " AUTO_INCREMENT"
+	}
+	if col.Unique {
+		def += " UNIQUE"
+	}
+	if col.NotNull {
+		def += " NOT NULL"
+	}
+	if col.Default != nil {
+		def += " DEFAULT " + quoteLiteral(col.Default, col)
+	}
+	if col.Check != nil {
+		def += fmt.Sprintf(" CHECK (%s)", col.Check)
+	}
+	return def
+}
+
+// SQLのキーワード（識別子として使う場合は引用符で囲む）
+var reservedWords = map[string]bool{
+	"ADD": true, "ALTER": true, "AND": true, "AS": true, "ASC": true, "BEGIN": true, "BETWEEN": true,
+	"BY": true, "CHECK": true, "COLUMN": true, "COMMENT": true, "COMMIT": true, "CREATE": true,
+	"DEFAULT": true, "DELETE": true, "DESC": true, "DISTINCT": true, "DROP": true, "FALSE": true,
+	"FOREIGN": true, "FROM": true, "GROUP": true, "IN": true, "INDEX": true, "INSERT": true, "INTO": true,
+	"IS": true, "JOIN": true, "KEY": true, "LIKE": true, "LIMIT": true, "NOT": true, "NULL": true,
+	"OFFSET": true, "ON": true, "OR": true, "ORDER": true, "PRIMARY": true, "REFERENCES": true,
+	"RETURNING": true, "ROLLBACK": true, "SELECT": true, "SET": true, "TABLE": true, "TRUE": true,
+	"UNIQUE": true, "UPDATE": true, "VALUES": true, "WHERE": true,
+}
+
+// 識別子のSQL表現（キーワードや英数字以外を含む名前は "name" の形で囲む）
+func quoteIdentifier(name string) string {
+	if identifierPattern.MatchString(name) && !reservedWords[strings.ToUpper(name)] {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// 値をカラムの型に応じたSQLリテラルに変換
+// 文字列は引用符で囲み、内部の引用符は二重化する
+func quoteLiteral(value interface{}, col Column) string {
+	if value == nil {
+		return "NULL"
+	}
+
+	switch col.Type {
+	case TypeInteger, TypeFloat, TypeDecimal:
+		return formatValue(value)
+	case TypeBoolean:
+		if b, ok := value.(bool); ok {
+			if b {
+				return "TRUE"
+			}
+			return "FALSE"
+		}
+	}
+
+	str := fmt.Sprintf("%v", value)
+	return "'" + strings.ReplaceAll(str, "'", "''") + "'"
+}
+
+// 空文字列をNULLとして扱う
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// 整合性違反
+type IntegrityViolation struct {
+	Table  string
+	Row    int // 1始まりの行番号（テーブル全体の違反は0）
+	Column string
+	Reason string
+}
+
+func (v *IntegrityViolation) Error() string {
+	if v.Row == 0 {
+		return fmt.Sprintf("%s.%s: %s", v.Table, v.Column, v.Reason)
+	}
+	return fmt.Sprintf("%s row %d, column '%s': %s", v.Table, v.Row, v.Column, v.Reason)
+}
+
+// 全テーブルの制約違反を検査する（最初の違反で止めずにすべて報告する）
+func (db *Database) VerifyIntegrity() []error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	names := []string{}
+	for name := range db.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []error
+	for _, name := range names {
+		violations = append(violations, db.Tables[name].verify()...)
+		violations = append(violations, db.verifyForeignKeys(db.Tables[name])...)
+	}
+	return violations
+}
+
+// 外部キーの参照先の検査
+func (db *Database) verifyForeignKeys(t *Table) []error {
+	var violations []error
+	for _, fk := range t.ForeignKeys {
+		for i, row := range t.Rows {
+			if err := db.checkReference(fk, row[fk.Column]); err != nil {
+				violations = append(violations, &IntegrityViolation{
+					Table:  t.Name,
+					Row:    i + 1,
+					Column: fk.Column,
+					Reason: fmt.Sprintf("foreign key value %v not found in %s(%s)", row[fk.Column], fk.RefTable, fk.RefColumn),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// テーブル単位の制約検査
+func (t *Table) verify() []error {
+	var violations []error
+	report := func(row int, column, format string, args ...interface{}) {
+		violations = append(violations, &IntegrityViolation{
+			Table:  t.Name,
+			Row:    row,
+			Column: column,
+			Reason: fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, col := range t.Columns {
+		seen := make(map[string]int) // 主キー・UNIQUEの値 -> 最初に出現した行番号
+		for i, row := range t.Rows {
+			value := row[col.Name]
+
+			// NOT NULL / PRIMARY KEY
+			if value == nil {
+				if col.Primary {
+					report(i+1, col.Name, "primary key cannot be null")
+				} else if col.NotNull {
+					report(i+1, col.Name, "NOT NULL constraint violated")
+				}
+				continue
+			}
+
+			// データ型（変換できても保存されている値の型が違う場合は比較結果が変わるため報告する）
+			if _, err := validateAndConvertValue(value, col, false); err != nil {
+				report(i+1, col.Name, "invalid value %v: %v", value, err)
+			} else if !storedTypeMatches(value, col) {
+				report(i+1, col.Name, "value %s is stored as the wrong type for %s", formatLiteral(value), col.Type)
+			}
+
+			// 主キー・UNIQUEの一意性
+			if col.Primary || col.Unique {
+				key := valueKey(value, col.Type)
+				if first, exists := seen[key]; exists {
+					kind := "primary key"
+					if !col.Primary {
+						kind = "unique"
+					}
+					report(i+1, col.Name, "duplicate %s value %v (first seen in row %d)", kind, value, first)
+				} else {
+					seen[key] = i + 1
+				}
+			}
+
+			// CHECK制約
+			if col.Check != nil {
+				if ok, err := evaluateWhere(row, col.Check); err != nil || !ok {
+					report(i+1, col.Name, "CHECK constraint violated: %s", col.Check)
+				}
+			}
+		}
+	}
+
+	// 複数カラムの主キーの一意性
+	if len(t.PrimaryKey) > 0 {
+		seen := make(map[string]int)
+		for i, row := range t.Rows {
+			key := t.primaryKeyKey(row)
+			if first, exists := seen[key]; exists {
+				report(i+1, strings.Join(t.PrimaryKey, ", "), "duplicate primary key value (%s) (first seen in row %d)", formatKey(row, t.PrimaryKey), first)
+			} else {
+				seen[key] = i + 1
+			}
+		}
+	}
+
+	// スキーマにないカラムの値
+	for i, row := range t.Rows {
+		for _, name := range slices.Sorted(maps.Keys(row)) {
+			if !t.hasColumn(name) {
+				report(i+1, name, "column is not defined in the table schema")
+			}
+		}
+	}
+
+	return violations
+}
+
+// 保存・読み込み後の値がカラムの型に対応するGoの型か（JSONの数値はfloat64で読み込まれる）
+func storedTypeMatches(value interface{}, col Column) bool {
+	switch col.Type {
+	case TypeInteger:
+		switch v := value.(type) {
+		case int:
+			return true
+		case float64:
+			return v == math.Trunc(v)
+		}
+		return false
+	case TypeFloat:
+		switch value.(type) {
+		case int, float64:
+			return true
+		}
+		return false
+	case TypeDecimal:
+		_, ok := value.(Decimal)
+		return ok
+	case TypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	default:
+		_, ok := value.(string)
+		return ok
+	}
+}
+
+// 一意性判定用のキー（typはカラムの型。DATE/DATETIMEだけ日時を正規化し、文字列はそのまま使う）
+// 整数は正確な値、それ以外の数値は型によらず値で比較する（型がない結果の行は""）
+func valueKey(v interface{}, typ DataType) string {
+	switch typ {
+	case TypeDate, TypeDateTime:
+		if t, ok := parseDateTime(fmt.Sprintf("%v", v)); ok {
+			return "t:" + t.Format(dateTimeLayout)
+		}
+	}
+	switch n := v.(type) {
+	case string:
+		return "s:" + n
+	case int:
+		return "n:" + strconv.Itoa(n)
+	case float64:
+		// JSONから読み込んだ整数値（float64）も整数と同じキーにする
+		if n == math.Trunc(n) && n >= math.MinInt64 && n < math.MaxInt64 {
+			return "n:" + strconv.FormatInt(int64(n), 10)
+		}
+	}
+	if n, ok := comparableNumber(v); ok {
+		return "n:" + strconv.FormatFloat(n, 'g', -1, 64)
+	}
+	return fmt.Sprintf("s:%v", v)
+}
+
+// クエリビルダー（SQL文字列を組み立てずにクエリを実行する）
+//
+//	result, err := db.Table("users").Where("age", ">", 18).OrderBy("name").Limit(10).Select("name", "age")
+type QueryBuilder struct {
+	db      *Database
+	table   string
+	where   *WhereExpr
+	orderBy []OrderByItem
+	limit   *int
+	offset  int
+	err     error
+}
+
+// テーブルに対するクエリビルダーを作成
+func (db *Database) Table(name string) *QueryBuilder {
+	return &QueryBuilder{db: db, table: name}
+}
+
+// WHERE条件を追加（複数指定した場合はANDで結合）
+func (b *QueryBuilder) Where(column, operator string, value interface{}) *QueryBuilder {
+	cond := &WhereExpr{
+		Cond: &WhereCondition{
+			Column:   column,
+			Operator: strings.ToUpper(operator),
+			Value:    value,
+		},
+	}
+	if b.where == nil {
+		b.where = cond
+	} else {
+		b.where = &WhereExpr{Op: "AND", Left: b.where, Right: cond}
+	}
+	return b
+}
+
+// 昇順の並べ替えを追加
+func (b *QueryBuilder) OrderBy(column string) *QueryBuilder {
+	b.orderBy = append(b.orderBy, OrderByItem{Column: column})
+	return b
+}
+
+// 降順の並べ替えを追加
+func (b *QueryBuilder) OrderByDesc(column string) *QueryBuilder {
+	b.orderBy = append(b.orderBy, OrderByItem{Column: column, Desc: true})
+	return b
+}
+
+// 取得する最大行数を設定
+func (b *QueryBuilder) Limit(n int) *QueryBuilder {
+	if n < 0 {
+		b.err = fmt.Errorf("invalid LIMIT value: %d", n)
+		return b
+	}
+	b.limit = &n
+	return b
+}
+
+// 読み飛ばす行数を設定
+func (b *QueryBuilder) Offset(n int) *QueryBuilder {
+	if n < 0 {
+		b.err = fmt.Errorf("invalid OFFSET value: %d", n)
+		return b
+	}
+	b.offset = n
+	return b
+}
+
+// SELECTを実行（カラム省略時は全カラム）
+func (b *QueryBuilder) Select(columns ...string) (*QueryResult, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(columns) == 0 {
+		columns = []string{"*"}
+	}
+	return b.db.Select(&SelectQuery{
+		Table:   b.table,
+		Columns: columns,
+		Where:   b.where,
+		OrderBy: b.orderBy,
+		Limit:   b.limit,
+		Offset:  b.offset,
+	})
+}
+
+// INSERTを実行
+func (b *QueryBuilder) Insert(values map[string]interface{}) error {
+	if b.err != nil {
+		return b.err
+	}
+	return b.db.Insert(b.table, values)
+}
+
+// UPDATEを実行
+func (b *QueryBuilder) Update(values map[string]interface{}) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.db.Update(b.table, values, b.where)
+}
+
+// DELETEを実行
+func (b *QueryBuilder) Delete() (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.db.Delete(b.table, b.where)
+}
+
+// CREATE INDEX実装
+func (db *Database) CreateIndex(name, tableName, colName string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if !table.hasColumn(colName) {
+		return fmt.Errorf("column '%s' does not exist", colName)
+	}
+	for _, t := range db.Tables {
+		for _, index := range t.Indexes {
+			if index.Name == name {
+				return fmt.Errorf("index '%s' already exists", name)
+			}
+		}
+	}
+
+	index := &Index{Name: name, Column: colName}
+	index.build(table.Rows, table.columnType(colName))
+	table.Indexes = append(table.Indexes, index)
+	db.schemaVersion++
+	return db.persist()
+}
+
+// インデックスの構築（typはカラムの型）
+func (idx *Index) build(rows []Row, typ DataType) {
+	idx.typ = typ
+	idx.entries = make(map[string][]int)
+	for i, row := range rows {
+		idx.add(row[idx.Column], i)
+	}
+}
+
+// インデックスへの登録（NULLは登録しない）
+func (idx *Index) add(value interface{}, pos int) {
+	if value == nil {
+		return
+	}
+	key := idx.key(value)
+	idx.entries[key] = append(idx.entries[key], pos)
+}
+
+// 値のキー（カラムの型に合わせる）
+func (idx *Index) key(value interface{}) string {
+	return valueKey(value, idx.typ)
+}
+
+// 値に一致する行位置
+func (idx *Index) lookup(value interface{}) []int {
+	return idx.entries[idx.key(value)]
+}
+
+// JSONから読み込んだDECIMALの値（文字列）を固定小数点数に戻す
+func (t *Table) restoreDecimals() {
+	for _, col := range t.Columns {
+		if col.Type != TypeDecimal {
+			continue
+		}
+		for _, row := range t.Rows {
+			if value := row[col.Name]; value != nil {
+				if d, err := validateAndConvertValue(value, col, false); err == nil {
+					row[col.Name] = d
+				}
+			}
+		}
+	}
+}
+
+// 全インデックスの再構築（行位置が変わる変更の後に呼ぶ）
+func (t *Table) rebuildIndexes() {
+	for _, index := range t.Indexes {
+		index.build(t.Rows, t.columnType(index.Column))
+	}
+	t.buildKeys()
+}
+
+// 主キー・UNIQUEカラムの重複チェック用インデックスの構築
+func (t *Table) buildKeys() {
+	t.keys = make(map[string]*Index)
+	for _, col := range t.Columns {
+		if col.Primary || col.Unique {
+			index := &Index{Column: col.Name}
+			index.build(t.Rows, col.Type)
+			t.keys[col.Name] = index
+		}
+	}
+	t.compositeKey = nil
+	if len(t.PrimaryKey) > 0 {
+		t.compositeKey = make(map[string][]int)
+		for i, row := range t.Rows {
+			key := t.primaryKeyKey(row)
+			t.compositeKey[key] = append(t.compositeKey[key], i)
+		}
+	}
+}
+
+// 複数カラムの主キーのキー
+func (t *Table) primaryKeyKey(row Row) string {
+	types := make([]DataType, len(t.PrimaryKey))
+	for i, col := range t.PrimaryKey {
+		types[i] = t.columnType(col)
+	}
+	return rowKey(row, t.PrimaryKey, types)
+}
+
+// 主キー・UNIQUEカラムの値の検索用インデックス（該当しないカラムはnil）
+func (t *Table) keyIndex(colName string) *Index {
+	if t.keys == nil {
+		t.buildKeys()
+	}
+	return t.keys[colName]
+}
+
+// AUTO_INCREMENTカラムを取得
+func (t *Table) autoincrementColumn() *Column {
+	for i := range t.Columns {
+		if t.Columns[i].Autoincrement {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// カラムに対するインデックスを取得
+func (t *Table) getIndex(colName string) *Index {
+	for _, index := range t.Indexes {
+		if index.Column == colName {
+			return index
+		}
+	}
+	return nil
+}
+
+// WHERE条件に一致する可能性のある行位置を返す
+// ANDで結合された等価条件にインデックスが使えればそれで絞り込み、使えなければ全行を返す
+func (t *Table) candidateRows(where *WhereExpr) []int {
+	if index, value := t.findIndexLookup(where); index != nil {
+		return index.lookup(value)
+	}
+
+	all := make([]int, len(t.Rows))
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+// インデックスで検索できる等価条件を探す
+func (t *Table) findIndexLookup(where *WhereExpr) (*Index, interface{}) {
+	if where == nil {
+		return nil, nil
+	}
+
+	switch where.Op {
+	case "AND":
+		if index, value := t.findIndexLookup(where.Left); index != nil {
+			return index, value
+		}
+		return t.findIndexLookup(where.Right)
+	case "":
+		cond := where.Cond
+		if cond.Operator == "=" && len(cond.Columns) == 0 && cond.Value != nil {
+			if index := t.getIndex(cond.Column); index != nil {
+				return index, cond.Value
+			}
+		}
+	}
+	return nil, nil
+}
+
+// 外部キーの参照先に値が存在するかチェック（NULLは常に許可）
+func (db *Database) checkReference(fk ForeignKey, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	parent, exists := db.Tables[fk.RefTable]
+	if !exists {
+		return fmt.Errorf("referenced table '%s' does not exist", fk.RefTable)
+	}
+
+	found := false
+	if index := parent.getIndex(fk.RefColumn); index != nil {
+		found = len(index.lookup(value)) > 0
+	} else {
+		for _, row := range parent.Rows {
+			if valuesEqual(row[fk.RefColumn], value) {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("foreign key violation: value %v for column '%s' does not exist in %s(%s)", value, fk.Column, fk.RefTable, fk.RefColumn)
+	}
+	return nil
+}
+
+// 親テーブルの値が子テーブルから参照されていないかチェック
+// ignoreには同じテーブル内で参照元として無視する行位置を指定する（自己参照用）
+func (db *Database) checkNotReferenced(parent *Table, colName string, value interface{}, ignore map[int]bool) error {
+	for _, child := range db.Tables {
+		for _, fk := range child.ForeignKeys {
+			if fk.RefTable != parent.Name || fk.RefColumn != colName {
+				continue
+			}
+			for i, row := range child.Rows {
+				if child == parent && ignore[i] {
+					continue
+				}
+				if valuesEqual(row[fk.Column], value) {
+					return fmt.Errorf("foreign key violation: %s(%s) value %v is referenced by table '%s'", parent.Name, colName, value, child.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 削除する行が参照されていないかチェック（RESTRICT）
+func (db *Database) checkRowsNotReferenced(parent *Table, removing map[int]bool) error {
+	for i := range removing {
+		for _, col := range parent.Columns {
+			value := parent.Rows[i][col.Name]
+			if value == nil {
+				continue
+			}
+			if err := db.checkNotReferenced(parent, col.Name, value, removing); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// TRUNCATE実装（スキーマを残して全行を削除）
+func (db *Database) Truncate(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, exists := db.Tables[name]
+	if !exists {
+		return fmt.Errorf("table '%s' does not exist", name)
+	}
+
+	all := make(map[int]bool, len(table.Rows))
+	for i := range table.Rows {
+		all[i] = true
+	}
+	if err := db.checkRowsNotReferenced(table, all); err != nil {
+		return err
+	}
+
+	table.Rows = []Row{}
+	table.AutoIncrement = 0
+	table.rebuildIndexes()
+	return db.persist()
+}
+
+// インデックスの再構築（現在の行から作り直す。tableNameが空の場合は全テーブル）
+func (db *Database) Reindex(tableName string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.reindex(tableName)
+}
+
+// インデックスの再構築（ロックは呼び出し側で取る）
+func (db *Database) reindex(tableName string) error {
+	if tableName == "" {
+		for _, table := range db.Tables {
+			table.rebuildIndexes()
+		}
+		return nil
+	}
+
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	table.rebuildIndexes()
+	return nil
+}
+
+// ヘルパー関数
+func (t *Table) hasColumn(name string) bool {
+	for _, col := range t.Columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// カラム定義を取得（返すポインタはt.Columnsの要素を直接指す）
+func (t *Table) getColumn(name string) *Column {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// カラムの型（存在しないカラムは""）
+func (t *Table) columnType(name string) DataType {
+	if col := t.getColumn(name); col != nil {
+		return col.Type
+	}
+	return ""
+}
+
+// データ型検証と変換
+// strictがtrueの場合、情報が失われる変換（小数の切り捨てなど）はエラーとする
+func validateAndConvertValue(value interface{}, col Column, strict bool) (interface{}, error) {
+	switch col.Type {
+	case TypeInteger:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case float64:
+			// 範囲外の値（1e20など）はintに変換すると別の値になるため拒否する
+			if math.IsNaN(v) || v >= math.MaxInt64 || v < math.MinInt64 {
+				return nil, fmt.Errorf("integer value %v out of range", v)
+			}
+			if strict && v != math.Trunc(v) {
+				return nil, fmt.Errorf("cannot convert %v to integer without loss", v)
+			}
+			return int(v), nil
+		case string:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer value '%s'", v)
+			}
+			return n, nil
+		default:
+			return nil, fmt.Errorf("invalid integer value")
+		}
+
+	case TypeVarchar:
+		if _, ok := value.(string); !ok && strict {
+			return nil, fmt.Errorf("invalid string value %v", value)
+		}
+		str := fmt.Sprintf("%v", value)
+		if col.Size > 0 && utf8.RuneCountInString(str) > col.Size { // サイズはバイト数ではなく文字数
+			return nil, fmt.Errorf("string too long (max %d)", col.Size)
+		}
+		return str, nil
+
+	case TypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil || strict {
+				return nil, fmt.Errorf("invalid boolean value '%s'", v)
+			}
+			return b, nil
+		default:
+			return nil, fmt.Errorf("invalid boolean value")
+		}
+
+	case TypeFloat:
+		var f float64
+		switch v := value.(type) {
+		case int:
+			f = float64(v)
+		case float64:
+			f = v
+		case string:
+			if strict {
+				return nil, fmt.Errorf("invalid float value '%s'", v)
+			}
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid float value '%s'", v)
+			}
+			f = n
+		default:
+			return nil, fmt.Errorf("invalid float value")
+		}
+		// NaN・無限大はJSONに保存できない
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("invalid float value %v", value)
+		}
+		return f, nil
+
+	case TypeDecimal:
+		var str string
+		switch v := value.(type) {
+		case int:
+			str = strconv.Itoa(v)
+		case float64:
+			str = strconv.FormatFloat(v, 'f', -1, 64)
+		case Decimal:
+			str = v.String()
+		case string:
+			if strict {
+				return nil, fmt.Errorf("invalid decimal value '%s'", v)
+			}
+			str = v
+		default:
+			return nil, fmt.Errorf("invalid decimal value")
+		}
+		return parseDecimal(str, col.Precision, col.Scale)
+
+	case TypeDate:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid date value %v", value)
+		}
+		t, err := time.Parse(dateLayout, str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date value '%s' (expected YYYY-MM-DD)", str)
+		}
+		return t.Format(dateLayout), nil
+
+	case TypeDateTime:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid datetime value %v", value)
+		}
+		t, ok := parseDateTime(str)
+		if !ok {
+			return nil, fmt.Errorf("invalid datetime value '%s' (expected YYYY-MM-DD HH:MM:SS)", str)
+		}
+		return t.Format(dateTimeLayout), nil
+	}
+
+	return nil, fmt.Errorf("unknown data type")
+}
+
+// WHERE条件式の評価
+func evaluateWhere(row Row, where *WhereExpr) (bool, error) {
+	switch where.Op {
+	case "AND":
+		left, err := evaluateWhere(row, where.Left)
+		if err != nil || !left {
+			return false, err
+		}
+		return evaluateWhere(row, where.Right)
+	case "OR":
+		left, err := evaluateWhere(row, where.Left)
+		if err != nil || left {
+			return left, err
+		}
+		return evaluateWhere(row, where.Right)
+	}
+	return evaluateCondition(row, where.Cond)
+}
+
+// 条件式が参照するカラム名の一覧
+func (e *WhereExpr) columns() []string {
+	if e.Cond == nil {
+		return append(e.Left.columns(), e.Right.columns()...)
+	}
+	if len(e.Cond.Columns) > 0 {
+		return e.Cond.Columns
+	}
+	if e.Cond.Expr != nil {
+		return e.Cond.Expr.columns()
+	}
+	return []string{e.Cond.Column}
+}
+
+// カラム名を置き換えた条件式のコピー
+func (e *WhereExpr) mapColumns(f func(string) (string, error)) (*WhereExpr, error) {
+	if e.Cond == nil {
+		left, err := e.Left.mapColumns(f)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.Right.mapColumns(f)
+		if err != nil {
+			return nil, err
+		}
+		return &WhereExpr{Op: e.Op, Left: left, Right: right}, nil
+	}
+
+	cond := *e.Cond
+	if len(cond.Columns) > 0 {
+		cond.Columns = make([]string, len(e.Cond.Columns))
+		for i, name := range e.Cond.Columns {
+			column, err := f(name)
+			if err != nil {
+				return nil, err
+			}
+			cond.Columns[i] = column
+		}
+	} else if cond.Expr != nil {
+		expr, err := cond.Expr.mapColumns(f)
+		if err != nil {
+			return nil, err
+		}
+		cond.Expr = expr
+	} else {
+		column, err := f(cond.Column)
+		if err != nil {
+			return nil, err
+		}
+		cond.Column = column
+	}
+	if cond.ValueColumn != "" {
+		column, err := f(cond.ValueColumn)
+		if err != nil {
+			return nil, err
+		}
+		cond.ValueColumn = column
+	}
+	return &WhereExpr{Cond: &cond}, nil
+}
+
+// 条件の値に含まれるプレースホルダの数
+func (e *WhereExpr) countPlaceholders() int {
+	if e == nil {
+		return 0
+	}
+	if e.Cond == nil {
+		return e.Left.countPlaceholders() + e.Right.countPlaceholders()
+	}
+	n := 0
+	values, ok := e.Cond.Value.([]interface{})
+	if !ok {
+		values = []interface{}{e.Cond.Value}
+	}
+	for _, v := range values {
+		if v == placeholderToken {
+			n++
+		}
+	}
+	return n
+}
+
+// プレースホルダを左から順にvaluesの値に置き換えた条件式（使った値はvaluesから取り除く）
+func (e *WhereExpr) bindPlaceholders(values *[]interface{}) *WhereExpr {
+	if e == nil {
+		return nil
+	}
+	if e.Cond == nil {
+		left := e.Left.bindPlaceholders(values)
+		return &WhereExpr{Op: e.Op, Left: left, Right: e.Right.bindPlaceholders(values)}
+	}
+
+	cond := *e.Cond
+	next := func(v interface{}) interface{} {
+		if v != placeholderToken {
+			return v
+		}
+		v = (*values)[0]
+		*values = (*values)[1:]
+		return v
+	}
+	if list, ok := cond.Value.([]interface{}); ok {
+		bound := make([]interface{}, len(list))
+		for i, v := range list {
+			bound[i] = next(v)
+		}
+		cond.Value = bound
+	} else {
+		cond.Value = next(cond.Value)
+	}
+	return &WhereExpr{Cond: &cond}
+}
+
+// 条件式のSQL表現（CHECK制約の表示用）
+func (e *WhereExpr) String() string {
+	if e.Cond == nil {
+		left, right := e.Left.String(), e.Right.String()
+		if e.Op == "AND" {
+			// ANDの中のORは括弧で優先順位を保つ
+			if e.Left.Op == "OR" {
+				left = "(" + left + ")"
+			}
+			if e.Right.Op == "OR" {
+				right = "(" + right + ")"
+			}
+		}
+		return left + " " + e.Op + " " + right
+	}
+
+	c := e.Cond
+	switch {
+	case len(c.Columns) > 0:
+		return fmt.Sprintf("(%s) %s %s", strings.Join(c.Columns, ", "), c.Operator, formatLiteralList(c.Value))
+	case c.Operator == "IN" || c.Operator == "NOT IN":
+		return fmt.Sprintf("%s %s %s", c.Column, c.Operator, formatLiteralList(c.Value))
+	case c.Operator == "BETWEEN" || c.Operator == "NOT BETWEEN":
+		bounds, _ := c.Value.([]interface{})
+		if len(bounds) == 2 {
+			return fmt.Sprintf("%s %s %s AND %s", c.Column, c.Operator, formatLiteral(bounds[0]), formatLiteral(bounds[1]))
+		}
+	}
+
+	if c.ValueColumn != "" {
+		return fmt.Sprintf("%s %s %s", c.Column, c.Operator, c.ValueColumn)
+	}
+	str := fmt.Sprintf("%s %s %s", c.Column, c.Operator, formatLiteral(c.Value))
+	if c.Escape != 0 {
+		str += " ESCAPE " + formatLiteral(string(c.Escape))
+	}
+	return str
+}
+
+// 値のSQLリテラル表現（型は値から判断する）
+func formatLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// 値リストのSQL表現
+func formatLiteralList(value interface{}) string {
+	items, _ := value.([]interface{})
+	literals := make([]string, len(items))
+	for i, item := range items {
+		literals[i] = formatLiteral(item)
+	}
+	return "(" + strings.Join(literals, ", ") + ")"
+}
+
+// WHERE条件の値をカラムの型に合わせたコピーを返す
+// （'20' と INTEGER カラムは数値として、5 と VARCHAR カラムは文字列として比較する）
+func (t *Table) coerceWhere(e *WhereExpr) (*WhereExpr, error) {
+	if e == nil {
+		return nil, nil
+	}
+	if e.Cond == nil {
+		left, err := t.coerceWhere(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := t.coerceWhere(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &WhereExpr{Op: e.Op, Left: left, Right: right}, nil
+	}
+
+	cond := *e.Cond
+	items, isList := cond.Value.([]interface{})
+	switch {
+	case cond.Expr != nil:
+		// 式の値は変換せずに比較する（カラムの存在だけを確認する。INTERVALを含む式は日時として比較する）
+		for _, name := range append(cond.Expr.columns(), cond.ValueColumn) {
+			if name != "" && t.getColumn(name) == nil {
+				return nil, fmt.Errorf("column '%s' does not exist", name)
+			}
+		}
+		if cond.Expr.hasInterval() {
+			cond.typ = TypeDateTime
+		}
+	case cond.ValueColumn != "":
+		// カラム同士の比較は値を変換せず、両方のカラムの存在だけを確認する
+		for _, name := range []string{cond.Column, cond.ValueColumn} {
+			col := t.getColumn(name)
+			if col == nil {
+				return nil, fmt.Errorf("column '%s' does not exist", name)
+			}
+			if isDateType(col.Type) {
+				cond.typ = col.Type
+			}
+		}
+	case len(cond.Columns) > 0 && isList && len(items) == len(cond.Columns):
+		// タプル比較は要素ごとに対応するカラムの型に合わせる
+		values := make([]interface{}, len(items))
+		cond.types = make([]DataType, len(items))
+		for i, name := range cond.Columns {
+			value, err := t.coerceConditionValue(name, cond.Operator, items[i])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+			cond.types[i] = t.columnType(name)
+		}
+		cond.Value = values
+	case len(cond.Columns) > 0:
+		// 要素数が合わないタプルは評価時にエラーになる
+	case isList:
+		// IN のリスト・BETWEEN の境界
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := t.coerceConditionValue(cond.Column, cond.Operator, item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		cond.Value = values
+		cond.typ = t.columnType(cond.Column)
+	default:
+		value, err := t.coerceConditionValue(cond.Column, cond.Operator, cond.Value)
+		if err != nil {
+			return nil, err
+		}
+		cond.Value = value
+		cond.typ = t.columnType(cond.Column)
+	}
+	return &WhereExpr{Cond: &cond}, nil
+}
+
+// 比較する値の型変換（BOOLEANカラムの大小比較など意味のない比較はエラー）
+func (t *Table) coerceConditionValue(name, operator string, value interface{}) (interface{}, error) {
+	col := t.getColumn(name)
+	if col == nil {
+		return nil, fmt.Errorf("column '%s' does not exist", name)
+	}
+	if col.Type == TypeBoolean {
+		switch operator {
+		case "=", "!=", "<>", "IS", "IS NOT", "IN", "NOT IN":
+		default:
+			return nil, fmt.Errorf("operator %s is not supported for boolean column '%s'", operator, col.Name)
+		}
+	}
+	// LIKEのパターンは文字列のまま
+	if value == nil || operator == "LIKE" {
+		return value, nil
+	}
+
+	converted, ok := value, true
+	switch col.Type {
+	case TypeVarchar:
+		converted = formatValue(value)
+	case TypeInteger, TypeFloat, TypeDecimal:
+		// 比較なので整数への切り捨てや桁数の制限はしない（age > 2.5 は 2.5 のまま）
+		if _, isBool := value.(bool); isBool {
+			ok = false
+		} else if str, isStr := value.(string); isStr {
+			converted, ok = toNumber(str)
+		}
+	case TypeBoolean:
+		var err error
+		converted, err = validateAndConvertValue(value, *col, false)
+		ok = err == nil
+	case TypeDate, TypeDateTime:
+		// DATEとDATETIMEは相互に比較できるので形式だけ確認する
+		str, isStr := value.(string)
+		if isStr {
+			_, ok = parseDateTime(str)
+		} else {
+			ok = false
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("cannot compare %s column '%s' with %s", col.Type, col.Name, formatLiteral(value))
+	}
+	return converted, nil
+}
+
+// 式が参照するカラム
+func (e *Expr) columns() []string {
+	if e.Op != "" {
+		return append(e.Left.columns(), e.Right.columns()...)
+	}
+	if e.Func != "" {
+		columns := []string{}
+		for _, arg := range e.Args {
+			columns = append(columns, arg.columns()...)
+		}
+		return columns
+	}
+	if e.Column != "" {
+		return []string{e.Column}
+	}
+	return nil
+}
+
+// INTERVALを含む式か（結果は日時になる）
+func (e *Expr) hasInterval() bool {
+	if e.Func == "INTERVAL" {
+		return true
+	}
+	if e.Op != "" {
+		return e.Left.hasInterval() || e.Right.hasInterval()
+	}
+	return slices.ContainsFunc(e.Args, (*Expr).hasInterval)
+}
+
+// カラム名を置き換えた式のコピー
+func (e *Expr) mapColumns(f func(string) (string, error)) (*Expr, error) {
+	if e.Op != "" {
+		left, err := e.Left.mapColumns(f)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.Right.mapColumns(f)
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Op: e.Op, Left: left, Right: right}, nil
+	}
+	if e.Func != "" {
+		args := make([]*Expr, len(e.Args))
+		for i, arg := range e.Args {
+			mapped, err := arg.mapColumns(f)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = mapped
+		}
+		return &Expr{Func: e.Func, Args: args}, nil
+	}
+	mapped := *e
+	if e.Column != "" {
+		column, err := f(e.Column)
+		if err != nil {
+			return nil, err
+		}
+		mapped.Column = column
+	}
+	return &mapped, nil
+}
+
+// 式の結合の強さ（葉は最大）
+func (e *Expr) precedence() int {
+	switch e.Op {
+	case "+", "-":
+		return 1
+	case "*", "/":
+		return 2
+	}
+	return 3
+}
+
+// 式のSQL表現（必要な場合のみ括弧を付ける）
+func (e *Expr) String() string {
+	if e.Func == "INTERVAL" && len(e.Args) == 1 {
+		return "INTERVAL " + e.Args[0].String()
+	}
+	if e.Func != "" {
+		args := make([]string, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = arg.String()
+		}
+		return e.Func + "(" + strings.Join(args, ", ") + ")"
+	}
+	if e.Op == "" {
+		if e.Column != "" {
+			return e.Column
+		}
+		return formatLiteral(e.Value)
+	}
+	left, right := e.Left.String(), e.Right.String()
+	if e.Left.precedence() < e.precedence() {
+		left = "(" + left + ")"
+	}
+	if e.Right.precedence() <= e.precedence() && e.Right.Op != "" {
+		right = "(" + right + ")"
+	}
+	return left + " " + e.Op + " " + right
+}
+
+// 式の評価（NULLを含む演算の結果はNULL）
+func evaluateExpr(row Row, e *Expr) (interface{}, error) {
+	if e.Func != "" {
+		args := make([]interface{}, len(e.Args))
+		for i, arg := range e.Args {
+			value, err := evaluateExpr(row, arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = value
+		}
+		return scalarFunctions[e.Func].call(args)
+	}
+	if e.Op == "" {
+		if e.Column != "" {
+			return row[e.Column], nil
+		}
+		return e.Value, nil
+	}
+
+	left, err := evaluateExpr(row, e.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := evaluateExpr(row, e.Right)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	return applyArithmetic(e.Op, left, right)
+}
+
+// 算術演算（整数同士の + - * は整数、/ とそれ以外は浮動小数点数。0による除算はエラー）
+func applyArithmetic(op string, a, b interface{}) (interface{}, error) {
+	// 日時と期間の加減（INTERVAL + 日時も可）
+	if interval, ok := b.(Interval); ok {
+		return addInterval(op, a, interval)
+	}
+	if interval, ok := a.(Interval); ok && op == "+" {
+		return addInterval(op, b, interval)
+	}
+	if _, ok := a.(Interval); ok {
+		return nil, fmt.Errorf("cannot apply '%s' to INTERVAL", op)
+	}
+
+	x, ok := toNumber(a)
+	if !ok {
+		return nil, fmt.Errorf("cannot apply '%s' to non-numeric value %s", op, formatLiteral(a))
+	}
+	y, ok := toNumber(b)
+	if !ok {
+		return nil, fmt.Errorf("cannot apply '%s' to non-numeric value %s", op, formatLiteral(b))
+	}
+
+	ai, aInt := a.(int)
+	bi, bInt := b.(int)
+	if aInt && bInt {
+		switch op {
+		case "+":
+			return ai + bi, nil
+		case "-":
+			return ai - bi, nil
+		case "*":
+			return ai * bi, nil
+		}
+	}
+
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		if y == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return x / y, nil
+	}
+	return nil, fmt.Errorf("unknown operator '%s'", op)
+}
+
+// スカラー関数（引数の数はminArgsからmaxArgsまで）
+type scalarFunction struct {
+	minArgs, maxArgs int
+	call             func(args []interface{}) (interface{}, error)
+}
+
+// 式で使えるスカラー関数（最初の引数がNULLの場合はNULLを返す）
+var scalarFunctions = map[string]scalarFunction{
+	"UPPER": {1, 1, nullable(func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(formatValue(args[0])), nil
+	})},
+	"LOWER": {1, 1, nullable(func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(formatValue(args[0])), nil
+	})},
+	"LENGTH": {1, 1, nullable(func(args []interface{}) (interface{}, error) {
+		return utf8.RuneCountInString(formatValue(args[0])), nil
+	})},
+	"ABS": {1, 1, nullable(func(args []interface{}) (interface{}, error) {
+		if n, ok := args[0].(int); ok {
+			return max(n, -n), nil
+		}
+		x, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("ABS requires a numeric argument: %s", formatLiteral(args[0]))
+		}
+		return math.Abs(x), nil
+	})},
+	"ROUND": {1, 2, nullable(func(args []interface{}) (interface{}, error) {
+		x, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("ROUND requires a numeric argument: %s", formatLiteral(args[0]))
+		}
+		digits := 0
+		if len(args) == 2 {
+			n, ok := args[1].(int)
+			if !ok {
+				return nil, fmt.Errorf("ROUND digits must be an integer: %s", formatLiteral(args[1]))
+			}
+			digits = n
+		}
+		if _, isInt := args[0].(int); isInt && digits >= 0 {
+			return args[0], nil
+		}
+		scale := math.Pow(10, float64(digits))
+		return math.Round(x*scale) / scale, nil
+	})},
+	// INTERVAL '7 days'（parseOperandが関数呼び出しとして組み立てる）
+	"INTERVAL": {1, 1, nullable(func(args []interface{}) (interface{}, error) {
+		return parseInterval(formatValue(args[0]))
+	})},
+	"NULLIF": {2, 2, nullable(func(args []interface{}) (interface{}, error) {
+		if args[1] != nil && compareValues(args[0], args[1]) == 0 {
+			return nil, nil
+		}
+		return args[0], nil
+	})},
+}
+
+// 日時に加減する期間（INTERVAL '7 days'）
+type Interval struct {
+	Amount int
+	Unit   string // "day", "hour", "minute"
+}
+
+func (iv Interval) String() string {
+	return fmt.Sprintf("%d %s", iv.Amount, plural(iv.Amount, iv.Unit, iv.Unit+"s"))
+}
+
+// INTERVALリテラルの解析（'7 days'、'-3 hours'。単位はday・hour・minuteで、複数形も可）
+func parseInterval(text string) (Interval, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 2 {
+		amount, err := strconv.Atoi(fields[0])
+		unit := strings.TrimSuffix(strings.ToLower(fields[1]), "s")
+		if err == nil && (unit == "day" || unit == "hour" || unit == "minute") {
+			return Interval{Amount: amount, Unit: unit}, nil
+		}
+	}
+	return Interval{}, fmt.Errorf("invalid INTERVAL '%s' (expected '<n> days|hours|minutes')", text)
+}
+
+// 日時への期間の加減（DATEの値に日単位の期間を加減した結果はDATE、それ以外はDATETIME）
+func addInterval(op string, value interface{}, interval Interval) (interface{}, error) {
+	if op != "+" && op != "-" {
+		return nil, fmt.Errorf("cannot apply '%s' to INTERVAL", op)
+	}
+	str, _ := value.(string)
+	t, ok := parseDateTime(str)
+	if !ok {
+		return nil, fmt.Errorf("cannot add INTERVAL to non-date value %s", formatLiteral(value))
+	}
+
+	amount := interval.Amount
+	if op == "-" {
+		amount = -amount
+	}
+	switch interval.Unit {
+	case "day":
+		t = t.AddDate(0, 0, amount)
+	case "hour":
+		t = t.Add(time.Duration(amount) * time.Hour)
+	case "minute":
+		t = t.Add(time.Duration(amount) * time.Minute)
+	}
+	if len(str) == len(dateLayout) && interval.Unit == "day" {
+		return t.Format(dateLayout), nil
+	}
+	return t.Format(dateTimeLayout), nil
+}
+
+// 最初の引数がNULLの場合はNULLを返す関数にする
+func nullable(f func(args []interface{}) (interface{}, error)) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		return f(args)
+	}
+}
+
+// WHERE条件（単一の比較）の評価
+func evaluateCondition(row Row, where *WhereCondition) (bool, error) {
+	// 左辺が式の場合は、式の値をColumnの値とした行で比較する
+	if where.Expr != nil {
+		value, err := evaluateExpr(row, where.Expr)
+		if err != nil {
+			return false, err
+		}
+		cond := *where
+		cond.Expr = nil
+		evaluated := Row{cond.Column: value}
+		if other, exists := row[cond.ValueColumn]; exists {
+			evaluated[cond.ValueColumn] = other
+		}
+		return evaluateCondition(evaluated, &cond)
+	}
+	if len(where.Columns) > 0 {
+		return evaluateTupleWhere(row, where)
+	}
+	if where.ValueColumn != "" {
+		other, exists := row[where.ValueColumn]
+		if !exists {
+			return false, fmt.Errorf("column '%s' does not exist", where.ValueColumn)
+		}
+		cond := *where
+		cond.Value, cond.ValueColumn = other, ""
+		return evaluateCondition(row, &cond)
+	}
+
+	value, exists := row[where.Column]
+	if !exists {
+		return false, fmt.Errorf("column '%s' does not exist", where.Column)
+	}
+
+	// NULLの扱い: IS / IS NOT はNULL同士を等しいとみなし、
+	// それ以外の比較はどちらかがNULLなら偽（= NULL も偽）
+	switch where.Operator {
+	case "IS":
+		return valuesEqual(value, where.Value), nil
+	case "IS NOT":
+		return !valuesEqual(value, where.Value), nil
+	}
+	if value == nil || where.Value == nil {
+		return false, nil
+	}
+
+	// 比較演算
+	switch where.Operator {
+	case "=":
+		return compareAs(value, where.Value, where.typ) == 0, nil
+	case "!=", "<>":
+		return compareAs(value, where.Value, where.typ) != 0, nil
+	case ">":
+		return compareAs(value, where.Value, where.typ) > 0, nil
+	case ">=":
+		return compareAs(value, where.Value, where.typ) >= 0, nil
+	case "<":
+		return compareAs(value, where.Value, where.typ) < 0, nil
+	case "<=":
+		return compareAs(value, where.Value, where.typ) <= 0, nil
+	case "LIKE":
+		return matchLike(fmt.Sprintf("%v", value), fmt.Sprintf("%v", where.Value), where.Escape), nil
+	case "IN", "NOT IN":
+		return evaluateIn(value, where)
+	case "BETWEEN", "NOT BETWEEN":
+		return evaluateBetween(value, where)
+	default:
+		return false, fmt.Errorf("unknown operator: %s", where.Operator)
+	}
+}
+
+// IN / NOT INの評価（空リストのINは常に偽、NULLを含むリストのNOT INは偽）
+func evaluateIn(value interface{}, where *WhereCondition) (bool, error) {
+	candidates, ok := where.Value.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s requires a list of values", where.Operator)
+	}
+
+	hasNull := false
+	for _, candidate := range candidates {
+		if candidate == nil {
+			hasNull = true
+			continue
+		}
+		if compareAs(value, candidate, where.typ) == 0 {
+			return where.Operator == "IN", nil
+		}
+	}
+
+	if where.Operator == "NOT IN" {
+		return !hasNull, nil
+	}
+	return false, nil
+}
+
+// BETWEEN / NOT BETWEENの評価（境界値を含む）
+func evaluateBetween(value interface{}, where *WhereCondition) (bool, error) {
+	bounds, ok := where.Value.([]interface{})
+	if !ok || len(bounds) != 2 {
+		return false, fmt.Errorf("%s requires two bounds", where.Operator)
+	}
+
+	if _, isBool := value.(bool); isBool {
+		return false, fmt.Errorf("%s is not supported for boolean column '%s'", where.Operator, where.Column)
+	}
+	_, valueIsNum := comparableNumber(value)
+	valueIsTime := isDateType(where.typ)
+	for _, bound := range bounds {
+		if bound == nil {
+			return false, fmt.Errorf("%s bounds cannot be NULL", where.Operator)
+		}
+		if _, boundIsNum := comparableNumber(bound); valueIsNum && !boundIsNum {
+			return false, fmt.Errorf("%s bound %v is not comparable with numeric column '%s'", where.Operator, bound, where.Column)
+		}
+		if _, boundIsTime := parseDateTime(fmt.Sprintf("%v", bound)); valueIsTime && !boundIsTime {
+			return false, fmt.Errorf("%s bound %v is not a valid date for column '%s'", where.Operator, bound, where.Column)
+		}
+	}
+
+	in := compareAs(value, bounds[0], where.typ) >= 0 && compareAs(value, bounds[1], where.typ) <= 0
+	if where.Operator == "NOT BETWEEN" {
+		return !in, nil
+	}
+	return in, nil
+}
+
+// タプル比較の評価（辞書順）
+func evaluateTupleWhere(row Row, where *WhereCondition) (bool, error) {
+	values, ok := where.Value.([]interface{})
+	if !ok || len(values) != len(where.Columns) {
+		return false, fmt.Errorf("tuple size mismatch")
+	}
+
+	cmp := 0
+	for i, colName := range where.Columns {
+		value, exists := row[colName]
+		if !exists {
+			return false, fmt.Errorf("column '%s' does not exist", colName)
+		}
+		// NULLを含むタプルは比較できない
+		if value == nil || values[i] == nil {
+			return false, nil
+		}
+		var typ DataType
+		if i < len(where.types) {
+			typ = where.types[i]
+		}
+		if cmp = compareAs(value, values[i], typ); cmp != 0 {
+			break
+		}
+	}
+
+	switch where.Operator {
+	case "=":
+		return cmp == 0, nil
+	case "!=", "<>":
+		return cmp != 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	default:
+		return false, fmt.Errorf("operator %s is not supported for tuples", where.Operator)
+	}
+}
+
+// 値の比較
+func compareValues(a, b interface{}) int {
+	// DECIMAL同士は誤差なく比較
+	if aDec, ok := a.(Decimal); ok {
+		if bDec, ok := b.(Decimal); ok {
+			return aDec.cmp(bDec)
+		}
+	}
+
+	// 整数同士は誤差なく比較（2^53を超える値はfloat64で区別できない）
+	if aInt, ok := a.(int); ok {
+		if bInt, ok := b.(int); ok {
+			return cmp.Compare(aInt, bInt)
+		}
+	}
+
+	// 数値比較
+	aNum, aIsNum := comparableNumber(a)
+	bNum, bIsNum := comparableNumber(b)
+	if aIsNum && bIsNum {
+		if aNum < bNum {
+			return -1
+		} else if aNum > bNum {
+			return 1
+		}
+		return 0
+	}
+
+	// 文字列比較（保存されたDATE/DATETIMEの値は形式が揃っているので文字列の順序で比較できる）
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// カラムの型に合わせた値の比較（DATE/DATETIMEは日時として比較し、DATEとDATETIMEの混在や'T'区切りも扱う）
+func compareAs(a, b interface{}, typ DataType) int {
+	if isDateType(typ) {
+		if aTime, ok := parseDateTime(fmt.Sprintf("%v", a)); ok {
+			if bTime, ok := parseDateTime(fmt.Sprintf("%v", b)); ok {
+				return aTime.Compare(bTime)
+			}
+		}
+	}
+	return compareValues(a, b)
+}
+
+// DATEまたはDATETIMEか
+func isDateType(typ DataType) bool {
+	return typ == TypeDate || typ == TypeDateTime
+}
+
+// 日付・日時文字列のパース（YYYY-MM-DD、YYYY-MM-DD HH:MM:SS）
+func parseDateTime(s string) (time.Time, bool) {
+	if len(s) < len(dateLayout) || s[4] != '-' {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{dateTimeLayout, "2006-01-02T15:04:05", dateLayout} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NULLの並び順（どちらかがNULLのときだけokを返す）
+// NULL同士は等しく、nullsFirstならNULLはどの値よりも小さく、そうでなければ大きい
+func compareNulls(a, b interface{}, nullsFirst bool) (int, bool) {
+	switch {
+	case a == nil && b == nil:
+		return 0, true
+	case a == nil && nullsFirst, b == nil && !nullsFirst:
+		return -1, true
+	case a == nil, b == nil:
+		return 1, true
+	}
+	return 0, false
+}
+
+// NULLを考慮した値の比較
+// NULLはどの値よりも大きいものとして扱う（標準SQLの昇順でNULLが末尾）
+func compareNullable(a, b interface{}) int {
+	if cmp, ok := compareNulls(a, b, false); ok {
+		return cmp
+	}
+	return compareValues(a, b)
+}
+
+// NULLを考慮した値の等価判定
+func valuesEqual(a, b interface{}) bool {
+	return compareNullable(a, b) == 0
+}
+
+// 行の等価判定（int/float64などの数値型の違いを吸収する）
+func RowEqual(a, b Row) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, av := range a {
+		bv, exists := b[key]
+		if !exists || !valuesEqual(av, bv) {
+			return false
+		}
+	}
+	return true
+}
+
+// 行リストの等価判定（順序も比較する）
+func RowsEqual(a, b []Row) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !RowEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// 行リストの差分を人間が読める形式で返す（差分がなければ空文字列）
+func DiffRows(a, b []Row) string {
+	var diffs []string
+	if len(a) != len(b) {
+		diffs = append(diffs, fmt.Sprintf("row count: %d != %d", len(a), len(b)))
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		keys := []string{}
+		for key := range a[i] {
+			keys = append(keys, key)
+		}
+		for key := range b[i] {
+			if _, exists := a[i][key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			av, aExists := a[i][key]
+			bv, bExists := b[i][key]
+			switch {
+			case !aExists:
+				diffs = append(diffs, fmt.Sprintf("row %d: column '%s' missing in first", i, key))
+			case !bExists:
+				diffs = append(diffs, fmt.Sprintf("row %d: column '%s' missing in second", i, key))
+			case !valuesEqual(av, bv):
+				diffs = append(diffs, fmt.Sprintf("row %d: column '%s': %v != %v", i, key, av, bv))
+			}
+		}
+	}
+
+	return strings.Join(diffs, "\n")
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case Decimal:
+		f, _ := strconv.ParseFloat(n.String(), 64)
+		return f, true
+	case string:
+		if f, err := strconv.ParseFloat(n, 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// 比較用の数値化（文字列は数字の並びでも文字列として扱う）
+func comparableNumber(v interface{}) (float64, bool) {
+	if _, ok := v.(string); ok {
+		return 0, false
+	}
+	return toNumber(v)
+}
+
+// 固定小数点数（値 = unscaled / 10^scale）。JSONには誤差が出ないよう文字列で保存する
+type Decimal struct {
+	unscaled int64
+	scale    int
+}
+
+// 文字列・数値からDECIMALへの変換（scale桁に四捨五入し、precision桁を超える値はエラー）
+func parseDecimal(s string, precision, scale int) (Decimal, error) {
+	if strings.Contains(s, "/") {
+		return Decimal{}, fmt.Errorf("invalid decimal value '%s'", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal value '%s'", s)
+	}
+
+	// 10^scale倍して四捨五入（0から遠い方向へ丸める）
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	r.Mul(r, new(big.Rat).SetInt(pow))
+	unscaled := roundHalfAway(r.Num(), r.Denom())
+
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+	if new(big.Int).Abs(unscaled).Cmp(limit) >= 0 {
+		return Decimal{}, fmt.Errorf("value %s out of range for DECIMAL(%d,%d)", s, precision, scale)
+	}
+	return Decimal{unscaled: unscaled.Int64(), scale: scale}, nil
+}
+
+// num / denomを0から遠い方向へ四捨五入した整数
+func roundHalfAway(num, denom *big.Int) *big.Int {
+	q, m := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(new(big.Int).Abs(denom)) >= 0 {
+		if num.Sign()*denom.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// 小数点以下scale桁の文字列表現
+func (d Decimal) String() string {
+	digits := strconv.FormatInt(d.unscaled, 10)
+	sign := ""
+	if d.unscaled < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+}
+
+// JSONには文字列として保存
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// DECIMAL同士の比較（スケールを揃えて整数で比較する）
+func (d Decimal) cmp(o Decimal) int {
+	a, b := big.NewInt(d.unscaled), big.NewInt(o.unscaled)
+	if d.scale < o.scale {
+		a.Mul(a, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(o.scale-d.scale)), nil))
+	} else if d.scale > o.scale {
+		b.Mul(b, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale-o.scale)), nil))
+	}
+	return a.Cmp(b)
+}
+
+// LIKE演算子の実装
+// escapeが0以外の場合、その文字の直後の文字（%や_を含む）はリテラルとして扱う
+func matchLike(str, pattern string, escape rune) bool {
+	// % を .* に、_ を . に変換し、それ以外の文字は正規表現のメタ文字としてエスケープする
+	var re strings.Builder
+	re.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		if escaped {
+			re.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+			continue
+		}
+		switch {
+		case escape != 0 && r == escape:
+			escaped = true
+		case r == '%':
+			re.WriteString(".*")
+		case r == '_':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	// 末尾のエスケープ文字はそれ自体をリテラルとして扱う
+	if escaped {
+		re.WriteString(regexp.QuoteMeta(string(escape)))
+	}
+	re.WriteString("$")
+
+	matched, _ := regexp.MatchString(re.String(), str)
+	return matched
+}
+
+// SELECT文を実行して結果の行を返す（SELECT以外の文はエラー）
+func (db *Database) Query(query string, args ...interface{}) ([]Row, error) {
+	if fields := strings.Fields(query); len(fields) == 0 || strings.ToUpper(fields[0]) != "SELECT" {
+		return nil, fmt.Errorf("only SELECT statements can be run with Query")
+	}
+
+	result, err := NewSQLParser(db).Exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return result.Rows, nil
+}
+
+// SQLスクリプトの実行（セミコロン区切りの文を順に実行し、最初のエラーで止める）
+func (db *Database) ExecScript(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = NewSQLParser(db).ParseAll(string(data))
+	return err
+}
+
+// スクリプト中の1文（lineは文が始まる行、1始まり）
+type scriptStatement struct {
+	text string
+	line int
+}
+
+// スクリプトをセミコロンで文に分割（引用符内のセミコロン・改行はそのまま）
+func splitStatements(script string) ([]scriptStatement, error) {
+	statements := []scriptStatement{}
+	var current strings.Builder
+	line, start := 1, 0
+	quoteChar := rune(0)
+
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			statements = append(statements, scriptStatement{text: text, line: start})
+		}
+		current.Reset()
+		start = 0
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		// コメントは空白1つに置き換える（行番号は数える）
+		if quoteChar == 0 {
+			end, err := commentEnd(runes, i)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			if end >= 0 {
+				line += strings.Count(string(runes[i:end]), "\n")
+				current.WriteRune(' ')
+				i = end - 1
+				continue
+			}
+		}
+
+		switch {
+		case quoteChar != 0:
+			if r == quoteChar {
+				quoteChar = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quoteChar = r
+		case r == ';':
+			flush()
+			continue
+		}
+
+		if start == 0 && !unicode.IsSpace(r) {
+			start = line
+		}
+		if r == '\n' {
+			line++
+		}
+		current.WriteRune(r)
+	}
+
+	if quoteChar == '"' || quoteChar == '`' {
+		return nil, fmt.Errorf("line %d: unterminated quoted identifier", start)
+	}
+	if quoteChar != 0 {
+		return nil, fmt.Errorf("line %d: unterminated string literal", start)
+	}
+	flush()
+	return statements, nil
+}
+
+// SQLパーサー実装
+func NewSQLParser(db *Database) *SQLParser {
+	return &SQLParser{
+		db:    db,
+		cache: newStatementCache(defaultStatementCacheSize),
+	}
+}
+
+// SQL文の実行（ライブラリとして利用する場合の入口。argsはプレースホルダ ? に順に割り当てる）
+func (p *SQLParser) Exec(query string, args ...interface{}) (*QueryResult, error) {
+	return p.ParseArgs(query, args...)
+}
+
+// セミコロン区切りの複数のSQL文を順に実行
+// 最初のエラーで止め、それまでの結果とエラーを返す（複数文の場合はエラーに文の開始行を付ける）
+func (p *SQLParser) ParseAll(script string) ([]*QueryResult, error) {
+	statements, err := splitStatements(script)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*QueryResult{}
+	for _, stmt := range statements {
+		result, err := p.Parse(stmt.text)
+		if err != nil {
+			if len(statements) > 1 {
+				err = fmt.Errorf("line %d: %w", stmt.line, err)
+			}
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SQL文のパースと実行
+func (p *SQLParser) Parse(query string) (*QueryResult, error) {
+	return p.ParseArgs(query)
+}
+
+// プレースホルダ付きSQL文のパースと実行
+// 引数は再トークン化せず、型を保ったままトークンとして埋め込む
+// 構文エラー（*SyntaxError）にはSQL文中の位置が設定される
+func (p *SQLParser) ParseArgs(query string, args ...interface{}) (result *QueryResult, err error) {
+	query = strings.TrimSpace(query)
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			locateSyntaxError(err, query)
+		}
+		if result != nil {
+			result.Duration = time.Since(start)
+		}
+	}()
+	tokens, plan, ok := p.cache.get(query)
+	if !ok {
+		var err error
+		if tokens, err = tokenize(query); err != nil {
+			return nil, err
+		}
+		p.cache.put(query, tokens)
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	tokens = p.db.foldIdentifiers(tokens)
+
+	// キャッシュしたSELECT文は解析結果を再利用し、プレースホルダの値だけを割り当てる
+	if ok && strings.ToUpper(tokens[0]) == "SELECT" {
+		if version := p.db.currentSchemaVersion(); plan == nil || plan.schemaVersion != version {
+			plan = planSelect(tokens, version)
+			p.cache.setPlan(query, plan)
+		}
+		if plan.query != nil {
+			q, err := plan.bind(args)
+			if err != nil {
+				return nil, err
+			}
+			return p.execSelect(q, plan.outfile)
+		}
+	}
+
+	// プレースホルダへの引数の割り当て（キャッシュしたトークンは書き換えない）
+	bound := slices.Clone(tokens)
+	n := 0
+	for i, token := range bound {
+		if token != placeholderToken {
+			continue
+		}
+		if n >= len(args) {
+			return nil, fmt.Errorf("not enough arguments for placeholders: got %d", len(args))
+		}
+		value, err := bindToken(args[n])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", n+1, err)
+		}
+		bound[i] = value
+		n++
+	}
+	if n != len(args) {
+		return nil, fmt.Errorf("too many arguments: %d placeholders, %d arguments", n, len(args))
+	}
+	tokens = bound
+
+	// 他のセッションのトランザクション中は変更できない（文の実行中は他のセッションもBEGINできない）
+	if modifyingCommands[strings.ToUpper(tokens[0])] && !p.db.ownsTransaction(p) {
+		if err := p.db.lockSession(); err != nil {
+			return nil, err
+		}
+		defer p.db.txMu.Unlock()
+	}
+
+	switch strings.ToUpper(tokens[0]) {
+	case "CREATE":
+		return p.parseCreate(tokens)
+	case "INSERT":
+		return p.parseInsert(tokens)
+	case "SELECT":
+		return p.parseSelect(tokens)
+	case "UPDATE":
+		return p.parseUpdate(tokens)
+	case "DELETE":
+		return p.parseDelete(tokens)
+	case "SET":
+		return p.parseSet(tokens)
+	case "SHOW":
+		return p.parseShow(tokens)
+	case "COMMENT":
+		return p.parseComment(tokens)
+	case "ALTER":
+		return p.parseAlter(tokens)
+	case "CHECK":
+		return p.parseCheck(tokens)
+	case "TRUNCATE":
+		return p.parseTruncate(tokens)
+	case "REINDEX":
+		return p.parseReindex(tokens)
+	case "IMPORT":
+		return p.parseImport(tokens)
+	case "DUMP":
+		return p.parseDump(tokens)
+	case "BEGIN", "START", "COMMIT", "ROLLBACK":
+		return p.parseTransaction(tokens)
+	default:
+		return nil, fmt.Errorf("unknown command: %s", tokens[0])
+	}
+}
+
+// データを変更する文の先頭のキーワード
+var modifyingCommands = map[string]bool{
+	"CREATE": true, "INSERT": true, "UPDATE": true, "DELETE": true, "COMMENT": true,
+	"ALTER": true, "TRUNCATE": true, "IMPORT": true,
+}
+
+// 文キャッシュ初期化（capacityが0の場合はキャッシュしない）
+func newStatementCache(capacity int) *statementCache {
+	return &statementCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// キャッシュからトークン列と解析結果を取得（トークン列は共有されるため変更しない）
+func (c *statementCache) get(query string) ([]string, *selectPlan, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[query]
+	if !ok {
+		return nil, nil, false
+	}
+	c.order.MoveToFront(elem)
+	entry := elem.Value.(*statementCacheEntry)
+	return entry.tokens, entry.plan, true
+}
+
+// キャッシュ済みのエントリにSELECT文の解析結果を登録
+func (c *statementCache) setPlan(query string, plan *selectPlan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[query]; ok {
+		elem.Value.(*statementCacheEntry).plan = plan
+	}
+}
+
+// キャッシュにトークン列を登録
+func (c *statementCache) put(query string, tokens []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity <= 0 {
+		return
+	}
+	if elem, ok := c.entries[query]; ok {
+		entry := elem.Value.(*statementCacheEntry)
+		entry.tokens = slices.Clone(tokens)
+		entry.plan = nil
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[query] = c.order.PushFront(&statementCacheEntry{
+		query:  query,
+		tokens: slices.Clone(tokens),
+	})
+	c.evict()
+}
+
+// キャッシュサイズ変更
+func (c *statementCache) resize(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = capacity
+	c.evict()
+}
+
+// 容量を超えた古いエントリを削除
+func (c *statementCache) evict() {
+	for c.order.Len() > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*statementCacheEntry).query)
+	}
+}
+
+// SELECT文をプレースホルダを含んだまま解析する。
+// プレースホルダがWHERE条件の値以外にある場合や解析エラーの場合は、queryがnilの計画を返す
+func planSelect(tokens []string, schemaVersion int) *selectPlan {
+	plan := &selectPlan{schemaVersion: schemaVersion}
+	query, outfile, err := parseSelectQuery(slices.Clone(tokens))
+	if err != nil {
+		return plan
+	}
+	for _, token := range tokens {
+		if token == placeholderToken {
+			plan.placeholders++
+		}
+	}
+	if query.Where.countPlaceholders() != plan.placeholders {
+		return plan
+	}
+	plan.query, plan.outfile = query, outfile
+	return plan
+}
+
+// 引数を割り当てたSELECT文（計画自体は複数の実行で共有するため変更しない）
+func (plan *selectPlan) bind(args []interface{}) (*SelectQuery, error) {
+	if len(args) < plan.placeholders {
+		return nil, fmt.Errorf("not enough arguments for placeholders: got %d", len(args))
+	}
+	if len(args) > plan.placeholders {
+		return nil, fmt.Errorf("too many arguments: %d placeholders, %d arguments", plan.placeholders, len(args))
+	}
+	if plan.placeholders == 0 {
+		return plan.query, nil
+	}
+
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		token, err := bindToken(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i+1, err)
+		}
+		values[i] = parseValue(token)
+	}
+	query := *plan.query
+	query.Where = plan.query.Where.bindPlaceholders(&values)
+	return &query, nil
+}
+
+// トークン化
+func tokenize(query string) ([]string, error) {
+	tokens, _, err := tokenizeWithPositions(query)
+	return tokens, err
+}
+
+// トークン化（各トークンが始まる文中の文字位置も返す。構文エラーの位置の表示用）
+func tokenizeWithPositions(query string) ([]string, []int, error) {
+	// 簡易的なトークン化（引用符内のスペースを保持）
+	var tokens []string
+	var positions []int
+	var current strings.Builder
+	start := 0 // currentが始まった位置
+	inQuote := false
+	quoteChar := rune(0)
+
+	emit := func(token string, pos int) {
+		tokens = append(tokens, token)
+		positions = append(positions, pos)
+	}
+	flush := func() {
+		if current.Len() > 0 {
+			emit(unquotedToken(current.String()), start)
+			current.Reset()
+		}
+	}
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		// 引用符の外のコメントは読み飛ばす（区切りとして扱う）
+		if !inQuote {
+			end, err := commentEnd(runes, i)
+			if err != nil {
+				return nil, nil, err
+			}
+			if end >= 0 {
+				flush()
+				i = end - 1
+				continue
+			}
+		}
+
+		if !inQuote && (r == '\'' || r == '"' || r == '`') {
+			// 修飾名の一部（"t"."c"、t."c"）は前の部分とつなげる
+			if r != '\'' && !strings.HasSuffix(current.String(), ".") {
+				flush()
+			}
+			if current.Len() == 0 {
+				start = i
+			}
+			inQuote = true
+			quoteChar = r
+		} else if inQuote && r == quoteChar && i+1 < len(runes) && runes[i+1] == quoteChar {
+			// 引用符の二重化（'O''Brien'、"a""b"）は引用符1文字
+			current.WriteRune(r)
+			i++
+		} else if inQuote && r == quoteChar {
+			inQuote = false
+			if quoteChar == '\'' {
+				emit(stringTokenPrefix+current.String(), start)
+			} else {
+				// "name" と `name` は識別子（予約語や空白を含む名前も使える）
+				if current.Len() == 0 || strings.HasSuffix(current.String(), ".") {
+					return nil, nil, fmt.Errorf("empty quoted identifier")
+				}
+				if i+1 < len(runes) && runes[i+1] == '.' {
+					continue
+				}
+				emit(current.String(), start)
+			}
+			current.Reset()
+		} else if !inQuote && (r == ' ' || r == '\t' || r == '\n' || r == ',') {
+			flush()
+			if r == ',' {
+				emit(",", i)
+			}
+		} else if !inQuote && (r == '(' || r == ')' || r == ';') {
+			flush()
+			emit(string(r), i)
+		} else {
+			if current.Len() == 0 && !inQuote {
+				start = i
+			}
+			current.WriteRune(r)
+		}
+	}
+
+	// 引用符が閉じられないまま終端に達した
+	if inQuote && quoteChar != '\'' {
+		return nil, nil, fmt.Errorf("unterminated quoted identifier")
+	}
+	if inQuote {
+		return nil, nil, fmt.Errorf("unterminated string literal")
+	}
+
+	flush()
+	return tokens, positions, nil
+}
+
+// runes[i]から始まるコメントの次の位置（コメントでなければ-1）
+// -- は行末まで（改行は含まない）、/* */ は閉じるまで
+func commentEnd(runes []rune, i int) (int, error) {
+	if i+1 >= len(runes) {
+		return -1, nil
+	}
+
+	switch {
+	case runes[i] == '-' && runes[i+1] == '-':
+		for j := i + 2; j < len(runes); j++ {
+			if runes[j] == '\n' {
+				return j, nil
+			}
+		}
+		return len(runes), nil
+	case runes[i] == '/' && runes[i+1] == '*':
+		for j := i + 2; j+1 < len(runes); j++ {
+			if runes[j] == '*' && runes[j+1] == '/' {
+				return j + 2, nil
+			}
+		}
+		return -1, fmt.Errorf("unterminated comment")
+	}
+	return -1, nil
+}
+
+// プレースホルダと文字列リテラルを表すトークン（識別子・数値・キーワードと区別するため制御文字を使う）
+// 引用符で囲まれた文字列と文字列の引数は stringTokenPrefix を付けたトークンになる
+const (
+	placeholderToken  = "\x00?"
+	stringTokenPrefix = "\x00s:"
+)
+
+// 文字列リテラルのトークンから中身を取り出す（それ以外のトークンはそのまま）
+func literalText(token string) string {
+	return strings.TrimPrefix(token, stringTokenPrefix)
+}
+
+// 引用符の外のトークン（? はプレースホルダ）
+func unquotedToken(token string) string {
+	if token == "?" {
+		return placeholderToken
+	}
+	return token
+}
+
+// 引数をparseValueで元の型に戻せるトークンに変換
+func bindToken(arg interface{}) (string, error) {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32:
+		return fmt.Sprintf("%d", v), nil
+	case float32:
+		return bindToken(float64(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("invalid float value %v", v)
+		}
+		str := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(str, ".") {
+			str += ".0"
+		}
+		return str, nil
+	case Decimal:
+		return v.String(), nil
+	case string:
+		return stringTokenPrefix + v, nil
+	case time.Time:
+		return stringTokenPrefix + v.Format(dateTimeLayout), nil
+	}
+	return "", fmt.Errorf("unsupported argument type %T", arg)
+}
+
+// CREATE TABLE パース
+func (p *SQLParser) parseCreate(tokens []string) (*QueryResult, error) {
+	if len(tokens) > 1 && strings.ToUpper(tokens[1]) == "INDEX" {
+		return p.parseCreateIndex(tokens)
+	}
+	if len(tokens) < 2 || strings.ToUpper(tokens[1]) != "TABLE" {
+		return nil, syntaxError(tokens, 1, "invalid CREATE TABLE syntax")
+	}
+	if len(tokens) < 4 || tokens[3] != "(" {
+		return nil, syntaxError(tokens, 3, "invalid CREATE TABLE syntax: expected '(' after table name")
+	}
+
+	tableName := tokens[2]
+
+	// カラム定義をパース
+	columns := []Column{}
+	foreignKeys := []ForeignKey{}
+	var primaryKey []string
+	i := 4 // '(' の後から開始
+
+	for i < len(tokens) && tokens[i] != ")" {
+		if tokens[i] == "," {
+			i++
+			continue
+		}
+
+		// テーブル制約: PRIMARY KEY ( column, ... )
+		if strings.ToUpper(tokens[i]) == "PRIMARY" {
+			if i+1 >= len(tokens) || strings.ToUpper(tokens[i+1]) != "KEY" {
+				return nil, syntaxError(tokens, i+1, "invalid PRIMARY KEY syntax")
+			}
+			if primaryKey != nil {
+				return nil, fmt.Errorf("multiple primary keys defined")
+			}
+			keyColumns, next, err := parseList(tokens, i+2)
+			if err != nil {
+				return nil, prefixSyntaxError(err, "invalid PRIMARY KEY syntax")
+			}
+			if len(keyColumns) == 0 {
+				return nil, fmt.Errorf("PRIMARY KEY requires at least one column")
+			}
+			primaryKey = keyColumns
+			i = next
+			continue
+		}
+
+		// テーブル制約: FOREIGN KEY ( column ) REFERENCES table ( column )
+		if strings.ToUpper(tokens[i]) == "FOREIGN" {
+			fk, next, err := parseForeignKey(tokens, i)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+			foreignKeys = append(foreignKeys, fk)
+			continue
+		}
+
+		col, next, err := parseColumnDef(tokens, i)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+
+		columns = append(columns, col)
+	}
+	if i >= len(tokens) {
+		return nil, syntaxError(tokens, i, "missing ')' in CREATE TABLE")
+	}
+	if len(columns) == 0 {
+		return nil, syntaxError(tokens, i, "CREATE TABLE requires at least one column")
+	}
+	if err := expectEnd(tokens, i+1, "CREATE TABLE"); err != nil {
+		return nil, err
+	}
+
+	err := p.db.CreateTableWithPrimaryKey(tableName, columns, primaryKey, foreignKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryResult{
+		Message: fmt.Sprintf("Table '%s' created successfully", tableName),
+	}, nil
+}
+
+// CREATE INDEX パース
+func (p *SQLParser) parseCreateIndex(tokens []string) (*QueryResult, error) {
+	// CREATE INDEX name ON table ( column )
+	if len(tokens) < 8 || strings.ToUpper(tokens[3]) != "ON" || tokens[5] != "(" || tokens[7] != ")" {
+		return nil, fmt.Errorf("invalid CREATE INDEX syntax")
+	}
+	if err := expectEnd(tokens, 8, "CREATE INDEX"); err != nil {
+		return nil, err
+	}
+
+	if err := p.db.CreateIndex(tokens[2], tokens[4], tokens[6]); err != nil {
+		return nil, err
+	}
+
+	return &QueryResult{
+		Message: fmt.Sprintf("Index '%s' created successfully", tokens[2]),
+	}, nil
+}
+
+// 外部キー定義パース（tokens[i]はFOREIGN）
+func parseForeignKey(tokens []string, i int) (ForeignKey, int, error) {
+	// FOREIGN KEY ( column ) REFERENCES table ( column )
+	if i+9 >= len(tokens) ||
+		strings.ToUpper(tokens[i+1]) != "KEY" || tokens[i+2] != "(" || tokens[i+4] != ")" ||
+		strings.ToUpper(tokens[i+5]) != "REFERENCES" || tokens[i+7] != "(" || tokens[i+9] != ")" {
+		return ForeignKey{}, i, fmt.Errorf("invalid FOREIGN KEY syntax")
+	}
+
+	fk := ForeignKey{
+		Column:    tokens[i+3],
+		RefTable:  tokens[i+6],
+		RefColumn: tokens[i+8],
+	}
+	return fk, i + 10, nil
+}
+
+// CHECK制約パース（tokens[i]は'('）
+// 戻り値の2番目は対応する')'の位置
+func parseCheckConstraint(tokens []string, i int) (*WhereExpr, int, error) {
+	if i >= len(tokens) || tokens[i] != "(" {
+		return nil, i, fmt.Errorf("CHECK requires a parenthesized condition")
+	}
+
+	// 対応する閉じ括弧を探す
+	depth, end := 0, -1
+	for j := i; j < len(tokens) && end == -1; j++ {
+		switch tokens[j] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				end = j
+			}
+		}
+	}
+	if end == -1 {
+		return nil, i, fmt.Errorf("missing ')' in CHECK constraint")
+	}
+
+	inner := tokens[i+1 : end]
+	expr, next, err := parseWhere(inner, 0)
+	if err != nil {
+		return nil, i, err
+	}
+	if next != len(inner) {
+		return nil, i, fmt.Errorf("unexpected token in CHECK constraint: %s", inner[next])
+	}
+	return expr, end, nil
+}
+
+// カラム定義パース（tokens[i]はカラム名）
+// 戻り値の2番目はカラム定義の次のトークン位置
+func parseColumnDef(tokens []string, i int) (Column, int, error) {
+	// カラム名
+	colName := tokens[i]
+	i++
+
+	// データ型
+	if i >= len(tokens) {
+		return Column{}, i, syntaxError(tokens, i, "missing data type for column %s", colName)
+	}
+
+	colType := DataType(strings.ToUpper(tokens[i]))
+	switch colType {
+	case "REAL", "DOUBLE":
+		colType = TypeFloat
+	case "TIMESTAMP":
+		colType = TypeDateTime
+	case "NUMERIC":
+		colType = TypeDecimal
+	}
+	if !colType.valid() {
+		return Column{}, i, syntaxError(tokens, i, "unknown data type '%s' for column '%s'", tokens[i], colName)
+	}
+	i++
+
+	col := Column{
+		Name: colName,
+		Type: colType,
+	}
+
+	// VARCHAR(size)の処理（サイズは必須）
+	if colType == TypeVarchar {
+		if i >= len(tokens) || tokens[i] != "(" {
+			return Column{}, i, syntaxError(tokens, i, "missing size for VARCHAR column %s", colName)
+		}
+		if i+2 >= len(tokens) || tokens[i+2] != ")" {
+			return Column{}, i, syntaxError(tokens, i+1, "invalid size for VARCHAR column %s", colName)
+		}
+		size, err := strconv.Atoi(tokens[i+1])
+		if err != nil || size <= 0 {
+			return Column{}, i, syntaxError(tokens, i+1, "invalid size for VARCHAR column %s: %s", colName, tokens[i+1])
+		}
+		col.Size = size
+		i += 3 // '(' size ')'
+	}
+
+	// DECIMAL(precision, scale)の処理（省略時はDECIMAL(10,0)）
+	if colType == TypeDecimal {
+		col.Precision = 10
+		if i < len(tokens) && tokens[i] == "(" {
+			end := i + 2
+			if end < len(tokens) && tokens[end] == "," {
+				end += 2
+			}
+			if end >= len(tokens) || tokens[end] != ")" {
+				return Column{}, i, syntaxError(tokens, i+1, "invalid precision for DECIMAL column %s", colName)
+			}
+			precision, err := strconv.Atoi(tokens[i+1])
+			if err != nil || precision <= 0 || precision > maxDecimalPrecision {
+				return Column{}, i, syntaxError(tokens, i+1, "invalid precision for DECIMAL column %s: %s (must be 1-%d)", colName, tokens[i+1], maxDecimalPrecision)
+			}
+			col.Precision = precision
+			if end == i+4 {
+				scale, err := strconv.Atoi(tokens[i+3])
+				if err != nil || scale < 0 || scale > precision {
+					return Column{}, i, syntaxError(tokens, i+3, "invalid scale for DECIMAL column %s: %s", colName, tokens[i+3])
+				}
+				col.Scale = scale
+			}
+			i = end + 1
+		}
+	}
+
+	// 制約の処理
+	for i < len(tokens) && tokens[i] != "," && tokens[i] != ")" && tokens[i] != ";" {
+		constraint := strings.ToUpper(tokens[i])
+		switch constraint {
+		case "NOT":
+			if i+1 >= len(tokens) || strings.ToUpper(tokens[i+1]) != "NULL" {
+				return Column{}, i, syntaxError(tokens, i+1, "expected NULL after NOT for column %s", colName)
+			}
+			col.NotNull = true
+			i++
+		case "NULL":
+			// NULLを許可（既定どおり）
+		case "PRIMARY":
+			if i+1 >= len(tokens) || strings.ToUpper(tokens[i+1]) != "KEY" {
+				return Column{}, i, syntaxError(tokens, i+1, "expected KEY after PRIMARY for column %s", colName)
+			}
+			col.Primary = true
+			i++
+		case "UNIQUE":
+			col.Unique = true
+		case "AUTO_INCREMENT", "AUTOINCREMENT":
+			col.Autoincrement = true
+		case "CHECK":
+			check, end, err := parseCheckConstraint(tokens, i+1)
+			if err != nil {
+				return Column{}, i, fmt.Errorf("column %s: %v", colName, err)
+			}
+			col.Check = check
+			i = end
+		case "DEFAULT":
+			if i+1 >= len(tokens) || tokens[i+1] == "," || tokens[i+1] == ")" {
+				return Column{}, i, syntaxError(tokens, i+1, "missing DEFAULT value for column %s", colName)
+			}
+			col.Default = parseValue(tokens[i+1])
+			i++
+		case "REFERENCES":
+			return Column{}, i, syntaxError(tokens, i,
+				"REFERENCES is not supported in a column definition; use FOREIGN KEY (%s) REFERENCES table(column) in CREATE TABLE", colName)
+		default:
+			return Column{}, i, syntaxError(tokens, i, "unexpected token in definition of column %s: %s", colName, displayToken(tokens[i]))
+		}
+		i++
+	}
+
+	return col, i, nil
+}
+
+// INSERT パース
+func (p *SQLParser) parseInsert(tokens []string) (*QueryResult, error) {
+	// INSERT OR REPLACE / INSERT OR IGNORE（INTOの位置をずらし、エラー位置のためにトークンはそのまま使う）
+	action := ConflictAbort
+	into := 1
+	if len(tokens) > 2 && strings.ToUpper(tokens[1]) == "OR" {
+		switch strings.ToUpper(tokens[2]) {
+		case "REPLACE":
+			action = ConflictReplace
+		case "IGNORE":
+			action = ConflictIgnore
+		default:
+			return nil, syntaxError(tokens, 2, "expected REPLACE or IGNORE after INSERT OR")
+		}
+		into = 3
+	}
+	if len(tokens) <= into || strings.ToUpper(tokens[into]) != "INTO" {
+		return nil, syntaxError(tokens, into, "invalid INSERT syntax")
+	}
+	if len(tokens) < into+3 {
+		return nil, syntaxError(tokens, len(tokens), "invalid INSERT syntax")
+	}
+
+	tableName := tokens[into+1]
+	if strings.ToUpper(tableName) == "VALUES" || tableName == "(" {
+		return nil, syntaxError(tokens, into+1, "missing table name after INTO")
+	}
+
+	// VALUES句を探す
+	valuesIndex := -1
+	for i := into + 2; i < len(tokens); i++ {
+		if strings.ToUpper(tokens[i]) == "VALUES" {
+			valuesIndex = i
+			break
+		}
+	}
+
+	if valuesIndex == -1 {
+		return nil, syntaxError(tokens, len(tokens), "missing VALUES clause")
+	}
+
+	// カラム名をパース（オプション。空のリストはカラム省略と同じ）
+	var columns []string
+	if tokens[into+2] == "(" {
+		list, next, err := parseListBefore(tokens, into+2, valuesIndex, "INSERT column list")
+		if err != nil {
+			return nil, err
+		}
+		for n, column := range list {
+			if slices.Contains(list[:n], column) {
+				return nil, syntaxError(tokens, into+3+2*n, "duplicate column '%s' in INSERT", column)
+			}
+		}
+		if next != valuesIndex {
+			return nil, syntaxError(tokens, next, "expected VALUES after column list")
+		}
+		columns = list
+	} else if valuesIndex != into+2 {
+		return nil, syntaxError(tokens, into+2, "expected column list or VALUES after table name")
+	}
+
+	tableColumns, err := p.db.columnNames(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// カラムが指定されていない場合は、テーブル定義の順序を使用
+	positional := len(columns) == 0
+	if positional {
+		columns = tableColumns
+	}
+
+	// 値のタプルをパース: VALUES (v1, v2), (v3, v4), ...
+	tuples := [][]string{}
+	i := valuesIndex + 1
+	for {
+		valueTokens, next, err := parseListBefore(tokens, i, len(tokens), "VALUES clause")
+		if err != nil {
+			return nil, err
+		}
+		tuples = append(tuples, valueTokens)
+		i = next
+		if i >= len(tokens) || tokens[i] != "," {
+			break
+		}
+		i++
+	}
+
+	// RETURNING句をパース（オプション）
+	returning, err := parseReturning(tokens, i)
+	if err != nil {
+		return nil, err
+	}
+	if returning == nil {
+		if err := expectEnd(tokens, i, "INSERT"); err != nil {
+			return nil, err
+		}
+	}
+
+	// 値の数のチェック（カラム省略時はテーブルの全カラム、指定時は指定したカラムと同じ数が必要）
+	rows := make([]map[string]interface{}, len(tuples))
+	for n, valueTokens := range tuples {
+		prefix := ""
+		if len(tuples) > 1 {
+			prefix = fmt.Sprintf("row %d: ", n+1)
+		}
+		if len(valueTokens) > len(columns) {
+			return nil, fmt.Errorf("%stoo many values: expected %d, got %d", prefix, len(columns), len(valueTokens))
+		}
+		if len(valueTokens) < len(columns) {
+			return nil, fmt.Errorf("%stoo few values: expected %d, got %d (no value for %s)",
+				prefix, len(columns), len(valueTokens), strings.Join(columns[len(valueTokens):], ", "))
+		}
+
+		// 値の解析
+		values := make(map[string]interface{})
+		for valueIndex, token := range valueTokens {
+			values[columns[valueIndex]] = parseValue(token)
+		}
+		rows[n] = values
+	}
+
+	if returning != nil {
+		return p.db.InsertRowsReturning(tableName, rows, action, returning)
+	}
+
+	inserted, conflicts, err := p.db.InsertRows(tableName, rows, action)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryResult{
+		Message: p.insertMessage(tableName, inserted, conflicts, action),
+	}, nil
+}
+
+// INSERTの結果メッセージ（AUTO_INCREMENTのテーブルには最後に採番した値を付ける）
+func (p *SQLParser) insertMessage(tableName string, inserted, conflicts int, action ConflictAction) string {
+	message := fmt.Sprintf("%d rows inserted", inserted)
+	if inserted == 1 {
+		message = "1 row inserted"
+	}
+	if inserted > 0 && p.db.hasAutoincrement(tableName) {
+		if inserted == 1 {
+			message += fmt.Sprintf(" (id = %d)", p.db.LastInsertID())
+		} else {
+			message += fmt.Sprintf(" (last id = %d)", p.db.LastInsertID())
+		}
+	}
+
+	switch {
+	case conflicts == 0:
+	case action == ConflictReplace && inserted == 0:
+		message = fmt.Sprintf("%d %s replaced", conflicts, plural(conflicts, "row", "rows"))
+	case action == ConflictReplace:
+		message += fmt.Sprintf(", %d %s replaced", conflicts, plural(conflicts, "row", "rows"))
+	default:
+		message += fmt.Sprintf(" (%d duplicate primary %s ignored)", conflicts, plural(conflicts, "key", "keys"))
+	}
+	return message
+}
+
+// 件数に応じて単数形・複数形を選ぶ
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// JOINの開始キーワード
+var joinKeywords = map[string]bool{
+	"JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true,
+}
+
+// FROMのテーブル名の後に続く句のキーワード（別名とはみなさない）
+var selectClauseKeywords = map[string]bool{
+	"WHERE": true, "GROUP": true, "ORDER": true, "LIMIT": true, "OFFSET": true,
+}
+
+// SELECTのカラムリストの終わり（FROMまたはFROMを省略した場合の次の句）
+func endOfSelectList(token string) bool {
+	keyword := strings.ToUpper(token)
+	return keyword == "FROM" || selectClauseKeywords[keyword] || token == ";"
+}
+
+// SELECTリストの項目の先頭にあるキーワードと同じ名前のカラム（SELECT "from", "order" FROM t）
+// 句のキーワードの直後に , FROM AS は続かないため、その場合はカラム名とみなす
+func keywordAsColumn(tokens []string, i int) bool {
+	if i+1 >= len(tokens) || (i > 0 && tokens[i-1] != "," && !strings.EqualFold(tokens[i-1], "SELECT") && !strings.EqualFold(tokens[i-1], "DISTINCT")) {
+		return false
+	}
+	next := strings.ToUpper(tokens[i+1])
+	return next == "," || next == "FROM" || next == "AS"
+}
+
+// SELECT パース
+func (p *SQLParser) parseSelect(tokens []string) (*QueryResult, error) {
+	query, outfile, err := parseSelectQuery(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return p.execSelect(query, outfile)
+}
+
+// SELECT文の解析（INTO OUTFILEの出力先も返す）
+func parseSelectQuery(tokens []string) (*SelectQuery, string, error) {
+	// INTO OUTFILE句を探す
+	outfile := ""
+	for j := 1; j+2 < len(tokens); j++ {
+		if strings.ToUpper(tokens[j]) == "INTO" && strings.ToUpper(tokens[j+1]) == "OUTFILE" {
+			outfile = literalText(tokens[j+2])
+			tokens = append(tokens[:j:j], tokens[j+3:]...)
+			break
+		}
+	}
+	if len(tokens) < 2 {
+		return nil, "", syntaxError(tokens, len(tokens), "invalid SELECT syntax")
+	}
+
+	// カラムをパース
+	columns := []string{}
+	exprs := []*Expr{}
+	aliases := []string{}
+	hasAlias, hasExpr := false, false
+	i := 1
+	distinct := strings.ToUpper(tokens[i]) == "DISTINCT"
+	if distinct {
+		i++
+	}
+	for i < len(tokens) && (!endOfSelectList(tokens[i]) || keywordAsColumn(tokens, i)) {
+		if tokens[i] == "," {
+			i++
+			continue
+		}
+
+		// 集約関数: NAME ( arg )、それ以外はカラム名または算術式（price * quantity）
+		if tokens[i] == "*" {
+			columns = append(columns, "*")
+			exprs = append(exprs, nil)
+			i++
+		} else if aggregateFunctions[strings.ToUpper(tokens[i])] && i+3 < len(tokens) && tokens[i+1] == "(" && tokens[i+3] == ")" {
+			columns = append(columns, fmt.Sprintf("%s(%s)", strings.ToUpper(tokens[i]), tokens[i+2]))
+			exprs = append(exprs, nil)
+			i += 4
+			if i < len(tokens) && isArithmeticOperator(tokens[i]) {
+				return nil, "", fmt.Errorf("aggregate functions cannot be used in expressions")
+			}
+		} else {
+			expr, next, err := parseExpr(tokens, i)
+			if err != nil {
+				return nil, "", err
+			}
+			i = next
+			if expr.Op == "" && expr.Column != "" {
+				columns = append(columns, expr.Column)
+				exprs = append(exprs, nil)
+			} else {
+				columns = append(columns, expr.String())
+				exprs = append(exprs, expr)
+				hasExpr = true
+			}
+		}
+
+		// 別名: expr AS alias / expr alias
+		alias := ""
+		if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "AS" {
+			alias = tokens[i+1]
+			i += 2
+		} else if i < len(tokens) && tokens[i] != "," && !endOfSelectList(tokens[i]) {
+			alias = tokens[i]
+			i++
+		}
+		aliases = append(aliases, alias)
+		if alias != "" {
+			hasAlias = true
+		}
+	}
+	if len(columns) == 0 {
+		return nil, "", syntaxError(tokens, i, "missing column list in SELECT")
+	}
+
+	// FROMを省略した場合はテーブルなしで式を1回だけ評価する（SELECT 1 + 2）
+	tableName, alias := "", ""
+	if i < len(tokens) && strings.ToUpper(tokens[i]) == "FROM" {
+		i++
+		if i >= len(tokens) || tokens[i] == ";" {
+			return nil, "", syntaxError(tokens, i, "missing table name")
+		}
+		tableName = tokens[i]
+		i++
+
+		// テーブルの別名: FROM users u / FROM users AS u
+		if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "AS" {
+			alias = tokens[i+1]
+			i += 2
+		} else if i < len(tokens) && tokens[i] != ";" &&
+			!selectClauseKeywords[strings.ToUpper(tokens[i])] && !joinKeywords[strings.ToUpper(tokens[i])] {
+			alias = tokens[i]
+			i++
+		}
+		if i < len(tokens) && joinKeywords[strings.ToUpper(tokens[i])] {
+			return nil, "", fmt.Errorf("JOIN is not supported")
+		}
+	} else if slices.Contains(columns, "*") {
+		return nil, "", fmt.Errorf("SELECT * requires a FROM clause")
+	}
+
+	query := &SelectQuery{
+		Table:    tableName,
+		Alias:    alias,
+		Columns:  columns,
+		Distinct: distinct,
+	}
+	if hasAlias {
+		query.Aliases = aliases
+	}
+	if hasExpr {
+		query.Exprs = exprs
+	}
+
+	// WHERE句をパース
+	if i < len(tokens) && strings.ToUpper(tokens[i]) == "WHERE" {
+		var err error
+		if query.Where, i, err = parseWhere(tokens, i+1); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// GROUP BY句をパース
+	if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "GROUP" && strings.ToUpper(tokens[i+1]) == "BY" {
+		i += 2
+		if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "ROLLUP" && tokens[i+1] == "(" {
+			var err error
+			if query.GroupBy, i, err = parseList(tokens, i+1); err != nil {
+				return nil, "", err
+			}
+			query.Rollup = true
+		}
+		for i < len(tokens) && !query.Rollup {
+			query.GroupBy = append(query.GroupBy, tokens[i])
+			i++
+			if i >= len(tokens) || tokens[i] != "," {
+				break
+			}
+			i++
+		}
+		if len(query.GroupBy) == 0 {
+			return nil, "", syntaxError(tokens, i, "missing GROUP BY column")
+		}
+	}
+
+	// ORDER BY句をパース
+	if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "ORDER" && strings.ToUpper(tokens[i+1]) == "BY" {
+		var err error
+		if query.OrderBy, i, err = parseOrderBy(tokens, i+2); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// LIMIT / OFFSET句をパース
+	for i < len(tokens) {
+		keyword := strings.ToUpper(tokens[i])
+		if keyword != "LIMIT" && keyword != "OFFSET" {
+			break
+		}
+		if i+1 >= len(tokens) || tokens[i+1] == ";" {
+			return nil, "", syntaxError(tokens, i+1, "missing %s value", keyword)
+		}
+		n, err := strconv.Atoi(tokens[i+1])
+		if err != nil || n < 0 {
+			return nil, "", syntaxError(tokens, i+1, "invalid %s value: %s", keyword, tokens[i+1])
+		}
+		if keyword == "LIMIT" {
+			query.Limit = &n
+		} else {
+			query.Offset = n
+		}
+		i += 2
+	}
+
+	if err := expectEnd(tokens, i, "SELECT"); err != nil {
+		return nil, "", err
+	}
+	return query, outfile, nil
+}
+
+// SELECT文の実行（outfileが空でなければ結果をCSVに書き出す）
+func (p *SQLParser) execSelect(query *SelectQuery, outfile string) (*QueryResult, error) {
+	result, err := p.db.selectAs(p, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if outfile != "" {
+		if err := result.WriteCSVFile(outfile); err != nil {
+			return nil, err
+		}
+		return &QueryResult{
+			Message: fmt.Sprintf("%d row(s) written to '%s'", len(result.Rows), outfile),
+		}, nil
+	}
+
+	return result, nil
+}
+
+// UPDATE パース
+func (p *SQLParser) parseUpdate(tokens []string) (*QueryResult, error) {
+	if len(tokens) > 0 && tokens[len(tokens)-1] == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) < 6 {
+		return nil, syntaxError(tokens, len(tokens), "invalid UPDATE syntax")
+	}
+
+	tableName := tokens[1]
+
+	if strings.ToUpper(tokens[2]) != "SET" {
+		return nil, syntaxError(tokens, 2, "missing SET clause")
+	}
+
+	// SET句をパース
+	updates := make(map[string]interface{})
+	i := 3
+
+	for {
+		if i+1 < len(tokens) && tokens[i+1] != "=" {
+			return nil, syntaxError(tokens, i+1, "invalid SET syntax: expected '='")
+		}
+		if i+2 >= len(tokens) {
+			return nil, syntaxError(tokens, i+2, "invalid SET syntax")
+		}
+
+		if isClauseBoundary(tokens[i+2]) {
+			return nil, syntaxError(tokens, i+2, "missing value for '%s' in SET clause", tokens[i])
+		}
+		colName := strings.TrimPrefix(tokens[i], tableName+".")
+		value := parseValue(tokens[i+2])
+		updates[colName] = value
+		i += 3
+
+		// 代入はカンマで区切る
+		if i >= len(tokens) || tokens[i] != "," {
+			break
+		}
+		i++
+	}
+
+	// FROM句をパース（オプション）
+	source := ""
+	if i < len(tokens) && strings.ToUpper(tokens[i]) == "FROM" {
+		if i+1 >= len(tokens) || isClauseBoundary(tokens[i+1]) {
+			return nil, syntaxError(tokens, i+1, "missing table name after FROM")
+		}
+		source = tokens[i+1]
+		i += 2
+	}
+
+	// WHERE句をパース
+	var where *WhereExpr
+	if i < len(tokens) && strings.ToUpper(tokens[i]) == "WHERE" {
+		var err error
+		if where, i, err = parseWhere(tokens, i+1); err != nil {
+			return nil, err
+		}
+	}
+
+	// RETURNING句をパース（オプション）
+	returning, err := parseReturning(tokens, i)
+	if err != nil {
+		return nil, err
+	}
+	if returning == nil {
+		if err := expectEnd(tokens, i, "UPDATE"); err != nil {
+			return nil, err
+		}
+	}
+	if returning != nil {
+		if source != "" {
+			return nil, fmt.Errorf("RETURNING is not supported with UPDATE ... FROM")
+		}
+		return p.db.UpdateReturning(tableName, updates, where, returning)
+	}
+
+	var count int
+	if source != "" {
+		count, err = p.db.UpdateFrom(tableName, updates, source, where)
+	} else {
+		count, err = p.db.Update(tableName, updates, where)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryResult{
+		Message: fmt.Sprintf("%d row(s) updated", count),
+	}, nil
+}
+
+// DELETE パース
+func (p *SQLParser) parseDelete(tokens []string) (*QueryResult, error) {
+	if len(tokens) > 0 && tokens[len(tokens)-1] == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) < 2 || strings.ToUpper(tokens[1]) != "FROM" {
+		return nil, syntaxError(tokens, 1, "invalid DELETE syntax")
+	}
+	if len(tokens) < 3 {
+		return nil, syntaxError(tokens, 2, "invalid DELETE syntax")
+	}
+
+	tableName := tokens[2]
+	if isClauseBoundary(tableName) {
+		return nil, syntaxError(tokens, 2, "missing table name after FROM")
+	}
+
+	// WHERE句をパース
+	var where *WhereExpr
+	i := 3
+	if i < len(tokens) && strings.ToUpper(tokens[i]) == "WHERE" {
+		var err error
+		if where, i, err = parseWhere(tokens, i+1); err != nil {
+			return nil, err
+		}
+	}
+
+	// RETURNING句をパース（オプション）
+	returning, err := parseReturning(tokens, i)
+	if err != nil {
+		return nil, err
+	}
+	if returning == nil {
+		if err := expectEnd(tokens, i, "DELETE"); err != nil {
+			return nil, err
+		}
+	}
+	if returning != nil {
+		return p.db.DeleteReturning(tableName, where, returning)
+	}
+
+	count, err := p.db.Delete(tableName, where)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryResult{
+		Message: fmt.Sprintf("%d row(s) deleted", count),
+	}, nil
+}
+
+// 構文エラー（問題のトークンと、SQL文中の位置を持つ）
+type SyntaxError struct {
+	Message string
+	Token   string // 問題のトークン（文の終わりに達した場合は空）
+	Index   int    // トークンの番号（0始まり）
+	Pos     int    // SQL文中の文字位置（0始まり。Parseが設定し、不明な場合は-1）
+	Query   string // エラーが起きたSQL文
+}
+
+func (e *SyntaxError) Error() string {
+	position := ""
+	if e.Pos >= 0 {
+		position = fmt.Sprintf(" (position %d)", e.Pos+1)
+	}
+	switch {
+	case e.Token == "":
+		return fmt.Sprintf("%s at end of statement%s", e.Message, position)
+	case strings.HasSuffix(e.Message, e.Token) || strings.Contains(e.Message, "'"+e.Token+"'"):
+		return e.Message + position
+	case strings.HasPrefix(e.Token, "'"):
+		return fmt.Sprintf("%s near %s%s", e.Message, e.Token, position)
+	}
+	return fmt.Sprintf("%s near '%s'%s", e.Message, e.Token, position)
+}
+
+// エラーの位置を示す2行（問題の行と、その位置を指す ^）。位置が不明な場合は空
+func (e *SyntaxError) Pointer() string {
+	if e.Pos < 0 {
+		return ""
+	}
+	runes := []rune(e.Query)
+	pos := min(e.Pos, len(runes))
+	start, end := pos, pos
+	for start > 0 && runes[start-1] != '\n' {
+		start--
+	}
+	for end < len(runes) && runes[end] != '\n' {
+		end++
+	}
+	return string(runes[start:end]) + "\n" + strings.Repeat(" ", pos-start) + "^"
+}
+
+// tokens[i]の構文エラー（iが末尾を越える場合は文の終わり）
+func syntaxError(tokens []string, i int, format string, args ...interface{}) error {
+	err := &SyntaxError{Message: fmt.Sprintf(format, args...), Index: i, Pos: -1}
+	if i < len(tokens) {
+		err.Token = displayToken(tokens[i])
+	}
+	return err
+}
+
+// 構文エラーの説明の前に句の情報を付ける（位置はParseで設定されるため、SyntaxErrorのまま返す）
+func prefixSyntaxError(err error, format string, args ...interface{}) error {
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Message = fmt.Sprintf(format, args...) + ": " + syntaxErr.Message
+		return syntaxErr
+	}
+	return fmt.Errorf("%s: %v", fmt.Sprintf(format, args...), err)
+}
+
+// エラーメッセージ用のトークンの表記（文字列リテラルは引用符で囲む）
+func displayToken(token string) string {
+	switch {
+	case token == placeholderToken:
+		return "?"
+	case strings.HasPrefix(token, stringTokenPrefix):
+		return "'" + literalText(token) + "'"
+	}
+	return token
+}
+
+// 構文エラーにSQL文中の位置を設定する（トークンの番号がずれている場合は同じトークンを探す）
+func locateSyntaxError(err error, query string) {
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Query != "" {
+		return
+	}
+	syntaxErr.Query = query
+	tokens, positions, tokenizeErr := tokenizeWithPositions(query)
+	if tokenizeErr != nil {
+		return
+	}
+	if syntaxErr.Token == "" {
+		syntaxErr.Pos = len([]rune(strings.TrimRight(query, "; \t\n")))
+		return
+	}
+	if syntaxErr.Index < len(tokens) && displayToken(tokens[syntaxErr.Index]) == syntaxErr.Token {
+		syntaxErr.Pos = positions[syntaxErr.Index]
+		return
+	}
+	if j := slices.IndexFunc(tokens, func(token string) bool { return displayToken(token) == syntaxErr.Token }); j >= 0 {
+		syntaxErr.Pos = positions[j]
+	}
+}
+
+// 文の末尾の確認（iが最後の句の次のトークン位置。末尾の ; は許す）
+func expectEnd(tokens []string, i int, statement string) error {
+	if i < len(tokens) && tokens[i] == ";" {
+		i++
+	}
+	if i < len(tokens) {
+		return syntaxError(tokens, i, "unexpected token in %s: %s", statement, displayToken(tokens[i]))
+	}
+	return nil
+}
+
+// RETURNING句パース（iは句の開始位置。句がない場合はnil）
+func parseReturning(tokens []string, i int) ([]string, error) {
+	if i >= len(tokens) || strings.ToUpper(tokens[i]) != "RETURNING" {
+		return nil, nil
+	}
+
+	// 末尾の ; は文の終わり
+	end := len(tokens)
+	if tokens[end-1] == ";" {
+		end--
+	}
+	columns := []string{}
+	for i++; i < end; i += 2 {
+		if tokens[i] == "," {
+			return nil, syntaxError(tokens, i, "expected column name in RETURNING clause")
+		}
+		columns = append(columns, tokens[i])
+		if i+1 < end && tokens[i+1] != "," {
+			return nil, syntaxError(tokens, i+1, "unexpected '%s' in RETURNING clause", tokens[i+1])
+		}
+	}
+	if len(columns) == 0 || tokens[end-1] == "," {
+		return nil, syntaxError(tokens, end, "RETURNING requires at least one column")
+	}
+	return columns, nil
+}
+
+// 値や名前の代わりに現れた区切り・句のキーワード（SET a = WHERE ... のような欠落の検出用）
+func isClauseBoundary(token string) bool {
+	switch strings.ToUpper(token) {
+	case ",", "(", ")", ";", "=", "SET", "FROM", "WHERE", "RETURNING", "VALUES":
+		return true
+	}
+	return false
+}
+
+// 算術演算子（結合の弱い順）
+var arithmeticOperators = [][]string{{"+", "-"}, {"*", "/"}}
+
+// 算術演算子か
+func isArithmeticOperator(token string) bool {
+	return slices.ContainsFunc(arithmeticOperators, func(ops []string) bool { return slices.Contains(ops, token) })
+}
+
+// 算術式パース（tokens[i]から。* / は + - より先に結合し、同じ強さの演算子は左から結合する）
+// 戻り値の2番目は式の次のトークン位置
+func parseExpr(tokens []string, i int) (*Expr, int, error) {
+	return parseBinaryExpr(tokens, i, 0)
+}
+
+// level番目の強さの演算子による二項演算のパース
+func parseBinaryExpr(tokens []string, i, level int) (*Expr, int, error) {
+	if level == len(arithmeticOperators) {
+		return parseOperand(tokens, i)
+	}
+
+	left, i, err := parseBinaryExpr(tokens, i, level+1)
+	if err != nil {
+		return nil, i, err
+	}
+	for i < len(tokens) && slices.Contains(arithmeticOperators[level], tokens[i]) {
+		right, next, err := parseBinaryExpr(tokens, i+1, level+1)
+		if err != nil {
+			return nil, next, err
+		}
+		left = &Expr{Op: tokens[i], Left: left, Right: right}
+		i = next
+	}
+	return left, i, nil
+}
+
+// 演算の対象（カラム・リテラル・括弧で囲んだ式）のパース
+func parseOperand(tokens []string, i int) (*Expr, int, error) {
+	if i >= len(tokens) || tokens[i] == "," || tokens[i] == ")" || tokens[i] == ";" ||
+		isArithmeticOperator(tokens[i]) || (strings.ToUpper(tokens[i]) == "FROM" && !keywordAsColumn(tokens, i)) {
+		return nil, i, syntaxError(tokens, i, "missing operand in expression")
+	}
+
+	token := tokens[i]
+	if token == "(" {
+		expr, next, err := parseExpr(tokens, i+1)
+		if err != nil {
+			return nil, next, err
+		}
+		if next >= len(tokens) || tokens[next] != ")" {
+			return nil, next, syntaxError(tokens, next, "missing ')' in expression")
+		}
+		return expr, next + 1, nil
+	}
+	// 関数呼び出し: NAME ( arg, ... )
+	if i+1 < len(tokens) && tokens[i+1] == "(" {
+		name := strings.ToUpper(token)
+		if aggregateFunctions[name] {
+			return nil, i, fmt.Errorf("aggregate functions cannot be used in expressions")
+		}
+		fn, ok := scalarFunctions[name]
+		if !ok {
+			return nil, i, syntaxError(tokens, i, "unknown function '%s'", token)
+		}
+		expr := &Expr{Func: name}
+		i += 2
+		for i < len(tokens) && tokens[i] != ")" {
+			if len(expr.Args) > 0 {
+				if tokens[i] != "," {
+					return nil, i, syntaxError(tokens, i, "missing ')' in %s", name)
+				}
+				i++
+			}
+			arg, next, err := parseExpr(tokens, i)
+			if err != nil {
+				return nil, next, err
+			}
+			expr.Args = append(expr.Args, arg)
+			i = next
+		}
+		if i >= len(tokens) {
+			return nil, i, syntaxError(tokens, i, "missing ')' in %s", name)
+		}
+		if len(expr.Args) < fn.minArgs || len(expr.Args) > fn.maxArgs {
+			return nil, i, fmt.Errorf("wrong number of arguments for %s: %d", name, len(expr.Args))
+		}
+		return expr, i + 1, nil
+	}
+
+	// 期間: INTERVAL '7 days'（保存できるよう文字列を引数とする関数呼び出しにする）
+	if strings.ToUpper(token) == "INTERVAL" && i+1 < len(tokens) && strings.HasPrefix(tokens[i+1], stringTokenPrefix) {
+		text := literalText(tokens[i+1])
+		if _, err := parseInterval(text); err != nil {
+			return nil, i + 1, syntaxError(tokens, i+1, "%v", err)
+		}
+		return &Expr{Func: "INTERVAL", Args: []*Expr{{Value: text}}}, i + 2, nil
+	}
+
+	// 引用符で囲まれた文字列・数値・NULL・TRUE/FALSEはリテラル、それ以外はカラム
+	value := parseValue(token)
+	if str, ok := value.(string); ok && str == token {
+		return &Expr{Column: token}, i + 1, nil
+	}
+	return &Expr{Value: value}, i + 1, nil
+}
+
+// WHERE句パース（iはWHEREの次のトークン位置）
+// ANDはORより優先して結合し、括弧で優先順位を変更できる。戻り値の2番目は条件式の次のトークン位置
+func parseWhere(tokens []string, i int) (*WhereExpr, int, error) {
+	expr, i, err := parseOrExpr(tokens, i)
+	if err != nil {
+		return nil, i, err
+	}
+	if i < len(tokens) && tokens[i] == ")" {
+		return nil, i, syntaxError(tokens, i, "unexpected ')' in WHERE clause")
+	}
+	return expr, i, nil
+}
+
+// ORで結合された条件のパース
+func parseOrExpr(tokens []string, i int) (*WhereExpr, int, error) {
+	left, i, err := parseAndExpr(tokens, i)
+	if err != nil {
+		return nil, i, err
+	}
+
+	for i < len(tokens) && strings.ToUpper(tokens[i]) == "OR" {
+		right, next, err := parseAndExpr(tokens, i+1)
+		if err != nil {
+			return nil, next, err
+		}
+		left = &WhereExpr{Op: "OR", Left: left, Right: right}
+		i = next
+	}
+	return left, i, nil
+}
+
+// ANDで結合された条件のパース
+func parseAndExpr(tokens []string, i int) (*WhereExpr, int, error) {
+	left, i, err := parsePrimaryExpr(tokens, i)
+	if err != nil {
+		return nil, i, err
+	}
+
+	for i < len(tokens) && strings.ToUpper(tokens[i]) == "AND" {
+		right, next, err := parsePrimaryExpr(tokens, i+1)
+		if err != nil {
+			return nil, next, err
+		}
+		left = &WhereExpr{Op: "AND", Left: left, Right: right}
+		i = next
+	}
+	return left, i, nil
+}
+
+// 括弧で囲まれた条件式または単一条件のパース
+func parsePrimaryExpr(tokens []string, i int) (*WhereExpr, int, error) {
+	// "(a, b) > (...)" や "(a) = (...)" はタプル比較として扱う
+	isTuple := i+2 < len(tokens) && (tokens[i+2] == "," || tokens[i+2] == ")")
+	if i < len(tokens) && tokens[i] == "(" && !isTuple {
+		if i+1 < len(tokens) && tokens[i+1] == ")" {
+			return nil, i + 1, syntaxError(tokens, i+1, "empty parentheses in WHERE clause")
+		}
+		expr, next, err := parseOrExpr(tokens, i+1)
+		if err != nil {
+			return nil, next, err
+		}
+		if next >= len(tokens) || tokens[next] != ")" {
+			return nil, next, syntaxError(tokens, next, "missing ')' in WHERE clause")
+		}
+		return expr, next + 1, nil
+	}
+
+	cond, next, err := parseCondition(tokens, i)
+	if err != nil {
+		return nil, next, err
+	}
+	return &WhereExpr{Cond: cond}, next, nil
+}
+
+// 単一条件のパース
+func parseCondition(tokens []string, i int) (*WhereCondition, int, error) {
+	// 左辺が式: UPPER(name) = 'A'、price * 2 > 10
+	// 式の文字列表現をカラム名とした条件として解析し、式を持たせる
+	if i+1 < len(tokens) && tokens[i] != "(" && (tokens[i+1] == "(" || isArithmeticOperator(tokens[i+1])) {
+		expr, next, err := parseExpr(tokens, i)
+		if err != nil {
+			return nil, i, err
+		}
+		rest := append([]string{expr.String()}, tokens[next:]...)
+		cond, end, err := parseCondition(rest, 0)
+		if err != nil {
+			return nil, i, err
+		}
+		cond.Expr = expr
+		return cond, next + end - 1, nil
+	}
+
+	// タプル比較: (col1, col2) op (val1, val2)
+	if i < len(tokens) && tokens[i] == "(" {
+		columns, next, err := parseList(tokens, i)
+		if err != nil {
+			return nil, i, err
+		}
+		if next+1 >= len(tokens) {
+			return nil, i, fmt.Errorf("invalid tuple comparison")
+		}
+		operator := strings.ToUpper(tokens[next])
+		items, end, err := parseList(tokens, next+1)
+		if err != nil {
+			return nil, i, err
+		}
+		if len(items) != len(columns) {
+			return nil, i, fmt.Errorf("tuple size mismatch: %d columns, %d values", len(columns), len(items))
+		}
+		values := make([]interface{}, len(items))
+		for j, item := range items {
+			values[j] = parseValue(item)
+		}
+		return &WhereCondition{
+			Columns:  columns,
+			Operator: operator,
+			Value:    values,
+		}, end, nil
+	}
+
+	// IN / NOT IN: col [NOT] IN (val1, val2, ...)
+	if i+2 < len(tokens) {
+		operator, listStart := "", 0
+		if strings.ToUpper(tokens[i+1]) == "IN" {
+			operator, listStart = "IN", i+2
+		} else if i+3 < len(tokens) && strings.ToUpper(tokens[i+1]) == "NOT" && strings.ToUpper(tokens[i+2]) == "IN" {
+			operator, listStart = "NOT IN", i+3
+		}
+		if operator != "" {
+			items, end, err := parseList(tokens, listStart)
+			if err != nil {
+				return nil, i, prefixSyntaxError(err, "invalid %s list", operator)
+			}
+			values := make([]interface{}, len(items))
+			for j, item := range items {
+				values[j] = parseValue(item)
+			}
+			return &WhereCondition{
+				Column:   tokens[i],
+				Operator: operator,
+				Value:    values,
+			}, end, nil
+		}
+	}
+
+	// IS NULL / IS NOT NULL
+	if i+2 < len(tokens) && strings.ToUpper(tokens[i+1]) == "IS" {
+		operator, next := "IS", i+2
+		if strings.ToUpper(tokens[next]) == "NOT" {
+			operator, next = "IS NOT", next+1
+		}
+		if next >= len(tokens) || strings.ToUpper(tokens[next]) != "NULL" {
+			return nil, i, syntaxError(tokens, next, "expected NULL after %s", operator)
+		}
+		return &WhereCondition{
+			Column:   tokens[i],
+			Operator: operator,
+			Value:    nil,
+		}, next + 1, nil
+	}
+
+	// BETWEEN / NOT BETWEEN: col [NOT] BETWEEN low AND high
+	if i+1 < len(tokens) {
+		operator, start := "", 0
+		if strings.ToUpper(tokens[i+1]) == "BETWEEN" {
+			operator, start = "BETWEEN", i+2
+		} else if i+2 < len(tokens) && strings.ToUpper(tokens[i+1]) == "NOT" && strings.ToUpper(tokens[i+2]) == "BETWEEN" {
+			operator, start = "NOT BETWEEN", i+3
+		}
+		if operator != "" {
+			if start+2 >= len(tokens) || strings.ToUpper(tokens[start+1]) != "AND" {
+				return nil, i, syntaxError(tokens, start+1, "invalid %s syntax: expected '%s low AND high'", operator, operator)
+			}
+			return &WhereCondition{
+				Column:   tokens[i],
+				Operator: operator,
+				Value:    []interface{}{parseValue(tokens[start]), parseValue(tokens[start+2])},
+			}, start + 3, nil
+		}
+	}
+
+	if i+2 < len(tokens) {
+		cond := &WhereCondition{
+			Column:   tokens[i],
+			Operator: strings.ToUpper(tokens[i+1]),
+		}
+		if !comparisonOperators[cond.Operator] && cond.Operator != "LIKE" {
+			return nil, i, syntaxError(tokens, i+1, "unknown operator '%s' in WHERE clause", tokens[i+1])
+		}
+		// 修飾されたカラム名（t.col）が右辺の場合はカラム同士の比較
+		if comparisonOperators[cond.Operator] && qualifiedColumnPattern.MatchString(tokens[i+2]) {
+			cond.ValueColumn = tokens[i+2]
+		} else {
+			cond.Value = parseValue(tokens[i+2])
+		}
+		next := i + 3
+
+		// LIKE ... ESCAPE 'c'
+		if cond.Operator == "LIKE" && next < len(tokens) && strings.ToUpper(tokens[next]) == "ESCAPE" {
+			if next+1 >= len(tokens) || len([]rune(literalText(tokens[next+1]))) != 1 {
+				return nil, i, syntaxError(tokens, next+1, "ESCAPE requires a single character")
+			}
+			cond.Escape = []rune(literalText(tokens[next+1]))[0]
+			next += 2
+		}
+		return cond, next, nil
+	}
+
+	return nil, i, syntaxError(tokens, len(tokens), "incomplete WHERE condition")
+}
+
+// ORDER BY句パース（iはORDER BYの次のトークン位置）
+// 戻り値の2番目は句の次のトークン位置
+func parseOrderBy(tokens []string, i int) ([]OrderByItem, int, error) {
+	items := []OrderByItem{}
+	for i < len(tokens) && tokens[i] != ";" {
+		item := OrderByItem{Column: tokens[i]}
+		i++
+
+		// 集約関数: NAME ( arg )
+		if i+2 < len(tokens) && tokens[i] == "(" && tokens[i+2] == ")" {
+			item.Column = fmt.Sprintf("%s(%s)", strings.ToUpper(item.Column), tokens[i+1])
+			i += 3
+		}
+
+		if i < len(tokens) {
+			switch strings.ToUpper(tokens[i]) {
+			case "ASC":
+				i++
+			case "DESC":
+				item.Desc = true
+				i++
+			}
+		}
+
+		// NULLS FIRST / NULLS LAST
+		if i < len(tokens) && strings.ToUpper(tokens[i]) == "NULLS" {
+			if i+1 >= len(tokens) || (strings.ToUpper(tokens[i+1]) != "FIRST" && strings.ToUpper(tokens[i+1]) != "LAST") {
+				return nil, i, syntaxError(tokens, i+1, "expected FIRST or LAST after NULLS")
+			}
+			item.Nulls = strings.ToUpper(tokens[i+1])
+			i += 2
+		}
+		items = append(items, item)
+
+		if i >= len(tokens) || tokens[i] != "," {
+			break
+		}
+		i++
+	}
+
+	if len(items) == 0 {
+		return nil, i, syntaxError(tokens, i, "missing ORDER BY column")
+	}
+	return items, i, nil
+}
+
+// 括弧で囲まれたカンマ区切りリストのパース（tokens[i]は'('）
+// 戻り値の2番目は')'の次のトークン位置
+func parseList(tokens []string, i int) ([]string, int, error) {
+	return parseListBefore(tokens, i, len(tokens), "")
+}
+
+// tokens[limit]より前で閉じるリストのパース（clauseはエラーメッセージに付ける句の名前）
+// 要素はカンマで区切った1トークンずつで、空の要素や区切りのない要素はエラーにする
+func parseListBefore(tokens []string, i, limit int, clause string) ([]string, int, error) {
+	in := ""
+	if clause != "" {
+		in = " in " + clause
+	}
+	if i >= limit || tokens[i] != "(" {
+		return nil, i, syntaxError(tokens, i, "expected '('%s", in)
+	}
+	i++
+
+	items := []string{}
+	if i < limit && tokens[i] == ")" {
+		return items, i + 1, nil
+	}
+	for {
+		if i >= limit {
+			return nil, i, syntaxError(tokens, i, "missing ')'%s", in)
+		}
+		if tokens[i] == "," || tokens[i] == "(" || tokens[i] == ")" {
+			return nil, i, syntaxError(tokens, i, "expected a value%s", in)
+		}
+		items = append(items, tokens[i])
+		i++
+		if i >= limit {
+			return nil, i, syntaxError(tokens, i, "missing ')'%s", in)
+		}
+		if tokens[i] == ")" {
+			return items, i + 1, nil
+		}
+		if tokens[i] != "," {
+			return nil, i, syntaxError(tokens, i, "expected ',' or ')'%s", in)
+		}
+		i++
+	}
+}
+
+// SHOW パース
+func (p *SQLParser) parseShow(tokens []string) (*QueryResult, error) {
+	if len(tokens) < 2 {
+		return nil, fmt.Errorf("invalid SHOW syntax")
+	}
+
+	switch strings.ToUpper(tokens[1]) {
+	case "TABLES":
+		if err := expectEnd(tokens, 2, "SHOW TABLES"); err != nil {
+			return nil, err
+		}
+		return p.db.ShowTables(), nil
+	case "COLUMNS":
+		if len(tokens) < 4 || strings.ToUpper(tokens[2]) != "FROM" {
+			return nil, fmt.Errorf("invalid SHOW COLUMNS syntax")
+		}
+		if err := expectEnd(tokens, 4, "SHOW COLUMNS"); err != nil {
+			return nil, err
+		}
+		return p.db.ShowColumns(tokens[3])
+	case "CREATE":
+		if len(tokens) < 4 || strings.ToUpper(tokens[2]) != "TABLE" {
+			return nil, fmt.Errorf("invalid SHOW CREATE TABLE syntax")
+		}
+		if err := expectEnd(tokens, 4, "SHOW CREATE TABLE"); err != nil {
+			return nil, err
+		}
+		return p.db.ShowCreateTable(tokens[3])
+	default:
+		return nil, fmt.Errorf("unknown SHOW target: %s", tokens[1])
+	}
+}
+
+// ALTER TABLE パース
+func (p *SQLParser) parseAlter(tokens []string) (*QueryResult, error) {
+	if len(tokens) < 5 || strings.ToUpper(tokens[1]) != "TABLE" {
+		return nil, fmt.Errorf("invalid ALTER TABLE syntax")
+	}
+
+	tableName := tokens[2]
+	i := 4
+	if strings.ToUpper(tokens[i]) == "COLUMN" {
+		i++
+	}
+	if i >= len(tokens) {
+		return nil, fmt.Errorf("missing column name")
+	}
+
+	switch strings.ToUpper(tokens[3]) {
+	case "ADD":
+		col, next, err := parseColumnDef(tokens, i)
+		if err != nil {
+			return nil, err
+		}
+		if err := expectEnd(tokens, next, "ALTER TABLE"); err != nil {
+			return nil, err
+		}
+		if err := p.db.AddColumn(tableName, col); err != nil {
+			return nil, err
+		}
+	case "DROP":
+		if err := expectEnd(tokens, i+1, "ALTER TABLE"); err != nil {
+			return nil, err
+		}
+		if err := p.db.DropColumn(tableName, tokens[i]); err != nil {
+			return nil, err
+		}
+	case "MODIFY":
+		col, next, err := parseColumnDef(tokens, i)
+		if err != nil {
+			return nil, err
+		}
+		if err := expectEnd(tokens, next, "ALTER TABLE"); err != nil {
+			return nil, err
+		}
+		if err := p.db.ModifyColumn(tableName, col); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown ALTER TABLE action: %s", tokens[3])
+	}
+
+	return &QueryResult{
+		Message: fmt.Sprintf("Table '%s' altered", tableName),
+	}, nil
+}
+
+// TRUNCATE パース
+func (p *SQLParser) parseTruncate(tokens []string) (*QueryResult, error) {
+	i := 1
+	if i < len(tokens) && strings.ToUpper(tokens[i]) == "TABLE" {
+		i++
+	}
+	if i >= len(tokens) || tokens[i] == ";" {
+		return nil, fmt.Errorf("missing table name")
+	}
+	if err := expectEnd(tokens, i+1, "TRUNCATE"); err != nil {
+		return nil, err
+	}
+
+	if err := p.db.Truncate(tokens[i]); err != nil {
+		return nil, err
+	}
+
+	return &QueryResult{
+		Message: fmt.Sprintf("Table '%s' truncated", tokens[i]),
+	}, nil
+}
+
+// REINDEX [TABLE table] パース
+func (p *SQLParser) parseReindex(tokens []string) (*QueryResult, error) {
+	if tokens[len(tokens)-1] == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	tableName := ""
+	switch {
+	case len(tokens) == 1:
+	case len(tokens) == 3 && strings.ToUpper(tokens[1]) == "TABLE":
+		tableName = tokens[2]
+	default:
+		return nil, fmt.Errorf("invalid REINDEX syntax: expected REINDEX [TABLE table]")
+	}
+
+	if err := p.db.Reindex(tableName); err != nil {
+		return nil, err
+	}
+	if tableName == "" {
+		return &QueryResult{Message: "All indexes rebuilt"}, nil
+	}
+	return &QueryResult{Message: fmt.Sprintf("Indexes of table '%s' rebuilt", tableName)}, nil
+}
+
+// IMPORT 'file.csv' INTO table パース
+func (p *SQLParser) parseImport(tokens []string) (*QueryResult, error) {
+	if len(tokens) > 0 && tokens[len(tokens)-1] == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) != 4 || strings.ToUpper(tokens[2]) != "INTO" {
+		return nil, fmt.Errorf("invalid IMPORT syntax: expected IMPORT 'file.csv' INTO table")
+	}
+
+	path := literalText(tokens[1])
+	count, err := p.db.ImportCSVFile(path, tokens[3])
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryResult{
+		Message: fmt.Sprintf("%d row(s) imported into '%s'", count, tokens[3]),
+	}, nil
+}
+
+// DUMP [TO 'file.sql'] パース（ファイル指定がない場合はスクリプトを結果として返す）
+func (p *SQLParser) parseDump(tokens []string) (*QueryResult, error) {
+	if len(tokens) > 0 && tokens[len(tokens)-1] == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	switch {
+	case len(tokens) == 1:
+		var script strings.Builder
+		if err := p.db.Dump(&script); err != nil {
+			return nil, err
+		}
+		if script.Len() == 0 {
+			return &QueryResult{Message: "No tables to dump"}, nil
+		}
+		return &QueryResult{Message: strings.TrimSuffix(script.String(), "\n")}, nil
+	case len(tokens) == 3 && strings.ToUpper(tokens[1]) == "TO":
+		path := literalText(tokens[2])
+		if err := p.db.DumpFile(path); err != nil {
+			return nil, err
+		}
+		return &QueryResult{Message: fmt.Sprintf("Database dumped to '%s'", path)}, nil
+	default:
+		return nil, fmt.Errorf("invalid DUMP syntax: expected DUMP [TO 'file.sql']")
+	}
+}
+
+// BEGIN / START TRANSACTION / COMMIT / ROLLBACK パース
+func (p *SQLParser) parseTransaction(tokens []string) (*QueryResult, error) {
+	keyword := strings.ToUpper(tokens[0])
+	rest := tokens[1:]
+	if len(rest) > 0 && rest[len(rest)-1] == ";" {
+		rest = rest[:len(rest)-1]
+	}
+	if keyword == "START" && (len(rest) == 0 || strings.ToUpper(rest[0]) != "TRANSACTION") {
+		return nil, fmt.Errorf("invalid START syntax: expected START TRANSACTION")
+	}
+	if len(rest) > 0 && (strings.ToUpper(rest[0]) == "TRANSACTION" || strings.ToUpper(rest[0]) == "WORK") {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected token after %s: %s", keyword, rest[0])
+	}
+
+	switch keyword {
+	case "BEGIN", "START":
+		if err := p.db.begin(p); err != nil {
+			return nil, err
+		}
+		return &QueryResult{Message: "Transaction started"}, nil
+	case "COMMIT":
+		if err := p.db.endTransaction(p, false); err != nil {
+			return nil, err
+		}
+		return &QueryResult{Message: "Transaction committed"}, nil
+	default:
+		if err := p.db.endTransaction(p, true); err != nil {
+			return nil, err
+		}
+		return &QueryResult{Message: "Transaction rolled back"}, nil
+	}
+}
+
+// CHECK DATABASE パース
+func (p *SQLParser) parseCheck(tokens []string) (*QueryResult, error) {
+	if len(tokens) < 2 || strings.ToUpper(tokens[1]) != "DATABASE" {
+		return nil, fmt.Errorf("invalid CHECK syntax")
+	}
+	if err := expectEnd(tokens, 2, "CHECK DATABASE"); err != nil {
+		return nil, err
+	}
+
+	violations := p.db.VerifyIntegrity()
+	if len(violations) == 0 {
+		return &QueryResult{
+			Message: "No integrity violations found",
+		}, nil
+	}
+
+	result := &QueryResult{
+		Columns: []string{"table", "row", "column", "problem"},
+		Rows:    []Row{},
+	}
+	for _, err := range violations {
+		v := err.(*IntegrityViolation)
+		var row interface{}
+		if v.Row > 0 {
+			row = v.Row
+		}
+		result.Rows = append(result.Rows, Row{
+			"table":   v.Table,
+			"row":     row,
+			"column":  v.Column,
+			"problem": v.Reason,
+		})
+	}
+	return result, nil
+}
+
+// COMMENT ON パース
+func (p *SQLParser) parseComment(tokens []string) (*QueryResult, error) {
+	if len(tokens) < 6 || strings.ToUpper(tokens[1]) != "ON" || strings.ToUpper(tokens[4]) != "IS" {
+		return nil, fmt.Errorf("invalid COMMENT syntax")
+	}
+
+	// コメントは文字列リテラル（NULLは空文字列と同じくコメントを削除する）
+	comment := ""
+	switch {
+	case strings.HasPrefix(tokens[5], stringTokenPrefix):
+		comment = literalText(tokens[5])
+	case strings.ToUpper(tokens[5]) != "NULL":
+		return nil, syntaxError(tokens, 5, "COMMENT requires a string literal or NULL")
+	}
+	if err := expectEnd(tokens, 6, "COMMENT"); err != nil {
+		return nil, err
+	}
+	switch strings.ToUpper(tokens[2]) {
+	case "TABLE":
+		if err := p.db.SetTableComment(tokens[3], comment); err != nil {
+			return nil, err
+		}
+	case "COLUMN":
+		parts := strings.SplitN(tokens[3], ".", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("column must be qualified as table.column")
+		}
+		if err := p.db.SetColumnComment(parts[0], parts[1], comment); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown COMMENT target: %s", tokens[2])
+	}
+
+	return &QueryResult{
+		Message: "Comment updated",
+	}, nil
+}
+
+// SET パース
+func (p *SQLParser) parseSet(tokens []string) (*QueryResult, error) {
+	if len(tokens) < 4 || tokens[2] != "=" {
+		return nil, fmt.Errorf("invalid SET syntax")
+	}
+	if err := expectEnd(tokens, 4, "SET"); err != nil {
+		return nil, err
+	}
+
+	option := strings.ToLower(tokens[1])
+	switch option {
+	case "strict":
+		on, err := parseSwitch(tokens[3])
+		if err != nil {
+			return nil, err
+		}
+		p.db.mu.Lock()
+		p.db.strict = on
+		p.db.mu.Unlock()
+	case "max_result_rows":
+		n, err := strconv.Atoi(tokens[3])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid max_result_rows value: %s", tokens[3])
+		}
+		p.db.mu.Lock()
+		p.db.maxResultRows = n
+		p.db.mu.Unlock()
+	case "storage_indent":
+		on, err := parseSwitch(tokens[3])
+		if err != nil {
+			return nil, err
+		}
+		p.db.mu.Lock()
+		p.db.indentJSON = on
+		p.db.mu.Unlock()
+	case "autosave":
+		on, err := parseSwitch(tokens[3])
+		if err != nil {
+			return nil, err
+		}
+		if err := p.db.SetAutoSave(on); err != nil {
+			return nil, err
+		}
+	case "ignore_case":
+		on, err := parseSwitch(tokens[3])
+		if err != nil {
+			return nil, err
+		}
+		p.db.mu.Lock()
+		p.db.ignoreCase = on
+		p.db.schemaVersion++ // 解析済みのSELECTは名前の解決が変わるため解析し直す
+		p.db.mu.Unlock()
+	case "statement_cache_size":
+		n, err := strconv.Atoi(tokens[3])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid statement_cache_size value: %s", tokens[3])
+		}
+		p.cache.resize(n)
+	case "max_result_rows_action":
+		var truncate bool
+		switch strings.ToUpper(tokens[3]) {
+		case "ERROR":
+			truncate = false
+		case "TRUNCATE":
+			truncate = true
+		default:
+			return nil, fmt.Errorf("invalid max_result_rows_action value: %s", tokens[3])
+		}
+		p.db.mu.Lock()
+		p.db.truncateResults = truncate
+		p.db.mu.Unlock()
+	default:
+		return nil, fmt.Errorf("unknown option: %s", tokens[1])
+	}
+
+	return &QueryResult{
+		Message: fmt.Sprintf("%s = %s", option, strings.ToLower(tokens[3])),
+	}, nil
+}
+
+// ON/OFFスイッチのパース
+func parseSwitch(token string) (bool, error) {
+	switch strings.ToUpper(token) {
+	case "ON", "TRUE", "1":
+		return true, nil
+	case "OFF", "FALSE", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid switch value: %s", token)
+}
+
+// 修飾されたカラム名（u.name）
+var qualifiedColumnPattern = regexp.MustCompile(`^[A-Za-z_]\w*\.[A-Za-z_]\w*$`)
+
+// 引用符なしで書ける識別子
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+// カラム同士を比較できる演算子
+var comparisonOperators = map[string]bool{
+	"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true,
+}
+
+// 小数・指数表記のリテラル（3.14、.5、-2.、1e3、-2.5E-3。整数はAtoiで先に判定する）
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// 値のパース
+func parseValue(token string) interface{} {
+	// 引用符で囲まれた文字列・割り当てた文字列引数はそのまま（'123' や 'NULL' も文字列）
+	if strings.HasPrefix(token, stringTokenPrefix) {
+		return literalText(token)
+	}
+
+	// NULL
+	if strings.ToUpper(token) == "NULL" {
+		return nil
+	}
+
+	// Boolean
+	if strings.ToUpper(token) == "TRUE" {
+		return true
+	}
+	if strings.ToUpper(token) == "FALSE" {
+		return false
+	}
+
+	// 数値
+	if num, err := strconv.Atoi(token); err == nil {
+		return num
+	}
+	if decimalPattern.MatchString(token) {
+		if num, err := strconv.ParseFloat(token, 64); err == nil {
+			return num
+		}
+	}
+
+	// それ以外は文字列
+	return token
+}
+
+// 値の表示用文字列（浮動小数点数は指数表記を避ける）
+func formatValue(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// エラー・メッセージ・空結果の表示（表示した場合はtrue）
+func (r *QueryResult) displayStatus() bool {
+	if r.Error != nil {
+		fmt.Printf("Error: %v\n", r.Error)
+		return true
+	}
+
+	timing := r.displayOptions().Timing
+	if r.Message != "" {
+		if timing {
+			fmt.Printf("%s (%s)\n", r.Message, formatDuration(r.Duration))
+		} else {
+			fmt.Println(r.Message)
+		}
+		return true
+	}
+
+	if len(r.Rows) == 0 {
+		if timing {
+			fmt.Println(r.Summary())
+		} else {
+			fmt.Println("No rows returned")
+		}
+		return true
+	}
+	return false
+}
+
+// 結果の末尾に表示する行数（DisplayOptions.Timingの場合は「(3 rows, 1.2ms)」の形で実行時間も含める）
+func (r *QueryResult) Summary() string {
+	if !r.displayOptions().Timing {
+		return fmt.Sprintf("%d row(s) returned", len(r.Rows))
+	}
+	return fmt.Sprintf("(%d %s, %s)", len(r.Rows), plural(len(r.Rows), "row", "rows"), formatDuration(r.Duration))
+}
+
+// 実行時間の表示（1ms未満は0.001ms単位、1秒未満は0.1ms単位、それ以上は0.01秒単位）
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+	case d < time.Second:
+		return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+	}
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
+
+// 結果表示
+func (r *QueryResult) Display() {
+	if r.displayStatus() {
+		return
+	}
+
+	opts := r.displayOptions()
+
+	// 各カラムの幅はヘッダーと値の最大長（displayMaxWidthで打ち切る）
+	cells := make([][]string, len(r.Rows))
+	widths := make([]int, len(r.Columns))
+	for i, col := range r.Columns {
+		widths[i] = displayWidth(col)
+	}
+	for i, row := range r.Rows {
+		cells[i] = make([]string, len(r.Columns))
+		for j, col := range r.Columns {
+			cell := opts.Null
+			if row[col] != nil {
+				cell = formatValue(row[col])
+			}
+			cells[i][j] = truncateCell(cell)
+			widths[j] = max(widths[j], displayWidth(cells[i][j]))
+		}
+	}
+	for i := range widths {
+		widths[i] = min(widths[i], displayMaxWidth)
+	}
+
+	sepWidth := displayWidth(opts.Separator)
+	total := sepWidth
+	for _, width := range widths {
+		total += width + 2 + sepWidth
+	}
+	rule := strings.Repeat("-", max(total, 1))
+
+	// ヘッダー表示
+	fmt.Println(rule)
+	for i, col := range r.Columns {
+		fmt.Printf("%s %s ", opts.Separator, padCell(truncateCell(col), widths[i], false))
+	}
+	fmt.Println(opts.Separator)
+	fmt.Println(rule)
+
+	// データ表示（数値は右揃え）
+	for i, row := range r.Rows {
+		for j, col := range r.Columns {
+			right := false
+			switch row[col].(type) {
+			case int, float64, Decimal:
+				right = true
+			}
+			fmt.Printf("%s %s ", opts.Separator, padCell(cells[i][j], widths[j], right))
+		}
+		fmt.Println(opts.Separator)
+	}
+	fmt.Println(rule)
+
+	fmt.Println(r.Summary())
+
+	if r.Warning != "" {
+		fmt.Printf("Warning: %s\n", r.Warning)
+	}
+}
+
+// 表形式で表示するカラムの最大幅（超える値は末尾を省略する）
+const displayMaxWidth = 40
+
+// 端末上の表示幅（全角文字は2桁）
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul),
+		r >= 0x3000 && r <= 0x303F, // 全角記号
+		r >= 0xFF01 && r <= 0xFF60, // 全角英数
+		r >= 0xFFE0 && r <= 0xFFE6:
+		return 2
+	}
+	return 1
+}
+
+// 最大幅を超える値の省略
+func truncateCell(s string) string {
+	if displayWidth(s) <= displayMaxWidth {
+		return s
+	}
+	width := 0
+	for i, r := range s {
+		if width+runeWidth(r) > displayMaxWidth-3 {
+			return s[:i] + "..."
+		}
+		width += runeWidth(r)
+	}
+	return s
+}
+
+// 幅に合わせた空白埋め
+func padCell(s string, width int, right bool) string {
+	pad := strings.Repeat(" ", max(width-displayWidth(s), 0))
+	if right {
+		return pad + s
+	}
+	return s + pad
+}
+
+// 拡張表示（1行ごとに「カラム名 | 値」を縦に並べる）
+func (r *QueryResult) DisplayExpanded() {
+	if r.displayStatus() {
+		return
+	}
+
+	opts := r.displayOptions()
+
+	width := 0
+	for _, col := range r.Columns {
+		width = max(width, displayWidth(col))
+	}
+
+	for i, row := range r.Rows {
+		fmt.Printf("-[ RECORD %d ]%s\n", i+1, strings.Repeat("-", max(width+3, 10)))
+		for _, col := range r.Columns {
+			value := opts.Null
+			if row[col] != nil {
+				value = formatValue(row[col])
+			}
+			fmt.Printf("%s %s %s\n", padCell(col, width, false), opts.Separator, value)
+		}
+	}
+
+	fmt.Println(r.Summary())
+
+	if r.Warning != "" {
+		fmt.Printf("Warning: %s\n", r.Warning)
+	}
+}
+
+// CSV出力（1行目はヘッダー、NULLは空フィールド）
+func (r *QueryResult) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(r.Columns); err != nil {
+		return err
+	}
+
+	for _, row := range r.Rows {
+		record := make([]string, len(r.Columns))
+		for i, col := range r.Columns {
+			if value := row[col]; value != nil {
+				record[i] = formatValue(value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// JSON出力（カラム名をキーとするオブジェクトの配列、NULLはnull）
+func (r *QueryResult) WriteJSON(w io.Writer) error {
+	rows := make([]Row, len(r.Rows))
+	for i, row := range r.Rows {
+		rows[i] = row
+		// DECIMALは精度を保ったまま数値として出力する
+		cloned := false
+		for col, value := range row {
+			if d, ok := value.(Decimal); ok {
+				if !cloned {
+					rows[i], cloned = maps.Clone(row), true
+				}
+				rows[i][col] = json.Number(d.String())
+			}
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+// CSVファイルへの出力
+func (r *QueryResult) WriteCSVFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := r.WriteCSV(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,2406 @@
+package rdbms
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// テスト用のメモリ上のデータベース
+func newTestDB(t testing.TB) (*Database, *SQLParser) {
+	t.Helper()
+	db := NewInMemoryDatabase("test")
+	return db, NewSQLParser(db)
+}
+
+// SQLを実行し、エラーならテストを失敗させる
+func mustExec(t testing.TB, p *SQLParser, query string, args ...interface{}) *QueryResult {
+	t.Helper()
+	result, err := p.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return result
+}
+
+// SQLがエラーになり、メッセージにwantを含むことを確認する
+func mustFail(t testing.TB, p *SQLParser, query, want string) {
+	t.Helper()
+	_, err := p.Exec(query)
+	if err == nil {
+		t.Fatalf("%s: expected error containing %q", query, want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("%s: error %q does not contain %q", query, err, want)
+	}
+}
+
+// 結果の1カラム分の値を文字列で取り出す
+func columnValues(result *QueryResult, col string) []string {
+	values := []string{}
+	for _, row := range result.Rows {
+		if row[col] == nil {
+			values = append(values, "NULL")
+		} else {
+			values = append(values, formatValue(row[col]))
+		}
+	}
+	return values
+}
+
+func assertValues(t testing.TB, result *QueryResult, col string, want ...string) {
+	t.Helper()
+	got := columnValues(result, col)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("column %s: got %v, want %v", col, got, want)
+	}
+}
+
+func TestUniqueKeysFollowDML(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, code VARCHAR(10) UNIQUE, v INTEGER)")
+	for i := 1; i <= 5; i++ {
+		mustExec(t, p, fmt.Sprintf("INSERT INTO t VALUES (%d, 'c%d', %d)", i, i, i))
+	}
+
+	mustFail(t, p, "INSERT INTO t VALUES (3, 'x', 0)", "duplicate primary key value: 3")
+	mustFail(t, p, "INSERT INTO t VALUES (6, 'c3', 0)", "UNIQUE column 'code'")
+	mustFail(t, p, "UPDATE t SET id = 4 WHERE id = 5", "duplicate primary key value: 4")
+
+	// 更新・削除で空いた値は再び使える
+	mustExec(t, p, "UPDATE t SET id = 50 WHERE id = 5")
+	mustExec(t, p, "INSERT INTO t VALUES (5, 'c5b', 55)")
+	mustExec(t, p, "DELETE FROM t WHERE id = 1")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 'c1', 11)")
+
+	// ROLLBACKで戻した行のキーも重複として扱う
+	mustExec(t, p, "BEGIN")
+	mustExec(t, p, "DELETE FROM t WHERE id = 2")
+	mustExec(t, p, "ROLLBACK")
+	mustFail(t, p, "INSERT INTO t VALUES (2, 'c2b', 0)", "duplicate primary key value: 2")
+
+	// ALTERで新たにUNIQUEになったカラム
+	mustExec(t, p, "ALTER TABLE t ADD COLUMN w INTEGER UNIQUE")
+	mustExec(t, p, "INSERT INTO t (id, code, w) VALUES (10, 'a', 1)")
+	mustFail(t, p, "INSERT INTO t (id, code, w) VALUES (11, 'b', 1)", "UNIQUE column 'w'")
+	mustExec(t, p, "ALTER TABLE t MODIFY v INTEGER UNIQUE")
+	mustFail(t, p, "INSERT INTO t (id, code, v) VALUES (12, 'c', 3)", "UNIQUE column 'v'")
+}
+
+func TestUniqueKeysAfterReload(t *testing.T) {
+	dir := t.TempDir()
+	db := NewDatabaseAt("test", dir)
+	p := NewSQLParser(db)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10))")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 'a')")
+
+	// JSONから読み込んだ値（float64）とINSERTの値（int）を同じキーとして扱う
+	loaded, err := LoadDatabaseAt("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustFail(t, NewSQLParser(loaded), "INSERT INTO t VALUES (1, 'b')", "duplicate primary key value: 1")
+}
+
+// 10万行のテーブルへのINSERT（重複チェックが行数に比例しないこと）
+func BenchmarkInsertLargeTable(b *testing.B) {
+	db, p := newTestDB(b)
+	mustExec(b, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(20))")
+	for i := 0; i < 100000; i++ {
+		if err := db.Insert("t", map[string]interface{}{"id": i, "name": "x"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.Insert("t", map[string]interface{}{"id": 100000 + i, "name": "y"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStrictAndLenientConversion(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, name VARCHAR(10))")
+
+	// 既定（lenient）: 小数は切り捨て、数値は文字列化する
+	mustExec(t, p, "INSERT INTO t VALUES (2.7, 123)")
+	mustExec(t, p, "INSERT INTO t VALUES ('42', 'x')")
+	result := mustExec(t, p, "SELECT * FROM t")
+	assertValues(t, result, "id", "2", "42")
+	assertValues(t, result, "name", "123", "x")
+
+	// strict: 情報が失われる変換と文字列以外のVARCHARを拒否する
+	mustExec(t, p, "SET strict = on")
+	mustFail(t, p, "INSERT INTO t VALUES (2.7, 'a')", "without loss")
+	mustFail(t, p, "INSERT INTO t VALUES (1, 123)", "invalid string value")
+	mustFail(t, p, "INSERT INTO t VALUES ('4.5', 'a')", "invalid integer value '4.5'")
+	mustFail(t, p, "UPDATE t SET id = 1.5", "without loss")
+	mustExec(t, p, "INSERT INTO t VALUES ('7', 'a')")
+	mustExec(t, p, "INSERT INTO t VALUES (3.0, 'b')")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t"), "id", "2", "42", "7", "3")
+
+	mustExec(t, p, "SET strict = off")
+	mustExec(t, p, "UPDATE t SET id = 9.9 WHERE name = 'b'")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE name = 'b'"), "id", "9")
+}
+
+func TestTupleComparison(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE e (day DATE, id INTEGER, seq INTEGER)")
+	for _, row := range []string{
+		"('2024-01-01', 1, 1)", "('2024-01-01', 2, 1)", "('2024-01-01', 2, 2)",
+		"('2024-01-02', 1, 1)", "('2024-01-02', 3, 1)",
+	} {
+		mustExec(t, p, "INSERT INTO e VALUES "+row)
+	}
+
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		{"(day, id) > ('2024-01-01', 2)", []string{"2024-01-02/1", "2024-01-02/3"}},
+		{"(day, id) >= ('2024-01-01', 2)", []string{"2024-01-01/2", "2024-01-01/2", "2024-01-02/1", "2024-01-02/3"}},
+		{"(day, id, seq) > ('2024-01-01', 2, 1)", []string{"2024-01-01/2", "2024-01-02/1", "2024-01-02/3"}},
+		{"(day, id, seq) >= ('2024-01-01', 2, 1)", []string{"2024-01-01/2", "2024-01-01/2", "2024-01-02/1", "2024-01-02/3"}},
+		{"(day, id, seq) >= ('2024-01-02', 3, 2)", []string{}},
+	}
+	for _, tt := range tests {
+		result := mustExec(t, p, "SELECT day, id FROM e WHERE "+tt.where+" ORDER BY day, id, seq")
+		got := []string{}
+		for _, row := range result.Rows {
+			got = append(got, fmt.Sprintf("%v/%v", row["day"], row["id"]))
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.where, got, tt.want)
+		}
+	}
+
+	mustFail(t, p, "SELECT * FROM e WHERE (day, id) > ('2024-01-01')", "tuple size mismatch")
+}
+
+func TestModifyColumn(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE users (id INTEGER PRIMARY KEY, email VARCHAR(10))")
+	mustExec(t, p, "INSERT INTO users VALUES (1, 'a@example')")
+
+	// 拡張はデータに触れずに成功する
+	mustExec(t, p, "ALTER TABLE users MODIFY email VARCHAR(200)")
+	mustExec(t, p, "INSERT INTO users VALUES (2, 'long-address@example.com')")
+
+	// 縮小は収まらない行があれば拒否し、定義は変わらない
+	mustFail(t, p, "ALTER TABLE users MODIFY email VARCHAR(10)", "row 2")
+	mustExec(t, p, "INSERT INTO users VALUES (3, 'another-long-address@example.com')")
+
+	// 主キーはMODIFYで指定しなくても維持される
+	mustExec(t, p, "ALTER TABLE users MODIFY id INTEGER")
+	mustFail(t, p, "INSERT INTO users VALUES (1, 'dup')", "duplicate primary key value: 1")
+
+	// 外部キーで結ばれたカラムの型変更は拒否する
+	mustExec(t, p, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, FOREIGN KEY (user_id) REFERENCES users(id))")
+	mustFail(t, p, "ALTER TABLE users MODIFY id VARCHAR(10)", "referenced by a foreign key in table 'orders'")
+	mustFail(t, p, "ALTER TABLE orders MODIFY user_id VARCHAR(10)", "has a foreign key to users(id)")
+}
+
+func TestStatementCachePlans(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10), v INTEGER)")
+	for i := 1; i <= 5; i++ {
+		mustExec(t, p, "INSERT INTO t VALUES (?, ?, ?)", i, fmt.Sprintf("n%d", i), i*10)
+	}
+
+	// 2回目以降は解析結果を再利用し、実行ごとに異なる値を割り当てる
+	for i := 0; i < 3; i++ {
+		query := "SELECT id FROM t WHERE (v BETWEEN ? AND ? OR id IN (?, ?)) AND name <> ? ORDER BY id"
+		result := mustExec(t, p, query, 20, 30+i*10, 5, i, "n3")
+		want := [][]string{{"2", "5"}, {"1", "2", "4", "5"}, {"2", "4", "5"}}[i]
+		assertValues(t, result, "id", want...)
+	}
+	query := "SELECT id FROM t WHERE (id, v) >= (?, ?)"
+	for i := 0; i < 2; i++ {
+		assertValues(t, mustExec(t, p, query, 4, 40), "id", "4", "5")
+	}
+
+	// 引数の数の誤りはキャッシュの有無によらず同じエラー
+	for i := 0; i < 2; i++ {
+		if _, err := p.Exec("SELECT id FROM t WHERE id = ?"); err == nil || !strings.Contains(err.Error(), "not enough arguments") {
+			t.Fatalf("expected not enough arguments, got %v", err)
+		}
+		if _, err := p.Exec("SELECT id FROM t WHERE id = ?", 1, 2); err == nil || !strings.Contains(err.Error(), "too many arguments") {
+			t.Fatalf("expected too many arguments, got %v", err)
+		}
+	}
+
+	// テーブル定義の変更後は解析し直す
+	mustExec(t, p, "SELECT name FROM t WHERE id = ?", 1)
+	mustExec(t, p, "SELECT name FROM t WHERE id = ?", 1)
+	mustExec(t, p, "ALTER TABLE t DROP COLUMN name")
+	if _, err := p.Exec("SELECT name FROM t WHERE id = ?", 1); err == nil {
+		t.Fatal("expected error for dropped column")
+	}
+	mustExec(t, p, "ALTER TABLE t ADD COLUMN name VARCHAR(10)")
+	assertValues(t, mustExec(t, p, "SELECT name FROM t WHERE id = ?", 1), "name", "NULL")
+}
+
+// 同じSELECT文の繰り返し実行（文キャッシュの有無の比較）
+func BenchmarkRepeatedSelect(b *testing.B) {
+	for _, size := range []int{defaultStatementCacheSize, 0} {
+		b.Run(fmt.Sprintf("cache=%d", size), func(b *testing.B) {
+			_, p := newTestDB(b)
+			mustExec(b, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(20), v INTEGER)")
+			for i := 0; i < 100; i++ {
+				mustExec(b, p, "INSERT INTO t VALUES (?, ?, ?)", i, "x", i%7)
+			}
+			mustExec(b, p, fmt.Sprintf("SET statement_cache_size = %d", size))
+
+			query := "SELECT id, name FROM t WHERE id = ? AND v >= ? AND name IN ('x', 'y') ORDER BY id LIMIT 10"
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				mustExec(b, p, query, i%100, 0)
+			}
+		})
+	}
+}
+
+func TestTransactionIsolation(t *testing.T) {
+	db, a := newTestDB(t)
+	b := NewSQLParser(db)
+	mustExec(t, a, "CREATE TABLE t (id INTEGER PRIMARY KEY, v INTEGER)")
+	mustExec(t, a, "INSERT INTO t VALUES (1, 10)")
+
+	mustExec(t, a, "BEGIN")
+	mustExec(t, a, "INSERT INTO t VALUES (2, 20)")
+	mustExec(t, a, "UPDATE t SET v = 11 WHERE id = 1")
+
+	// 他のセッションはBEGIN時点のデータを読み、変更・COMMIT・BEGINはできない
+	assertValues(t, mustExec(t, a, "SELECT v FROM t ORDER BY id"), "v", "11", "20")
+	assertValues(t, mustExec(t, b, "SELECT v FROM t ORDER BY id"), "v", "10")
+	assertValues(t, mustExec(t, b, "SELECT v FROM t WHERE id = ?", 1), "v", "10")
+	mustFail(t, b, "INSERT INTO t VALUES (3, 30)", "locked by a transaction in another session")
+	mustFail(t, b, "DELETE FROM t", "locked by a transaction in another session")
+	mustFail(t, b, "COMMIT", "locked by a transaction in another session")
+	mustFail(t, b, "BEGIN", "locked by a transaction in another session")
+	mustFail(t, a, "BEGIN", "transaction already in progress")
+
+	mustExec(t, a, "COMMIT")
+	assertValues(t, mustExec(t, b, "SELECT v FROM t ORDER BY id"), "v", "11", "20")
+
+	// BのトランザクションのROLLBACKはAの変更に影響しない
+	mustExec(t, b, "BEGIN")
+	mustExec(t, b, "DELETE FROM t WHERE id = 2")
+	assertValues(t, mustExec(t, a, "SELECT id FROM t ORDER BY id"), "id", "1", "2")
+	mustExec(t, b, "ROLLBACK")
+	mustExec(t, a, "INSERT INTO t VALUES (3, 30)")
+	assertValues(t, mustExec(t, b, "SELECT id FROM t ORDER BY id"), "id", "1", "2", "3")
+}
+
+// 他のgoroutineのSELECTは確定前の行を読まない
+func TestTransactionIsolationConcurrent(t *testing.T) {
+	db, writer := newTestDB(t)
+	mustExec(t, writer, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+
+	const batches, batchSize = 20, 5
+	done := make(chan struct{})
+	errs := make(chan error, 4)
+	for r := 0; r < 4; r++ {
+		go func() {
+			reader := NewSQLParser(db)
+			for {
+				select {
+				case <-done:
+					errs <- nil
+					return
+				default:
+				}
+				result, err := reader.Exec("SELECT COUNT(*) FROM t")
+				if err != nil {
+					errs <- err
+					return
+				}
+				if n := result.Rows[0]["COUNT(*)"].(int); n%batchSize != 0 {
+					errs <- fmt.Errorf("read uncommitted count %d", n)
+					return
+				}
+			}
+		}()
+	}
+
+	id := 0
+	for i := 0; i < batches; i++ {
+		mustExec(t, writer, "BEGIN")
+		for j := 0; j < batchSize; j++ {
+			id++
+			mustExec(t, writer, "INSERT INTO t VALUES (?)", id)
+		}
+		mustExec(t, writer, "COMMIT")
+	}
+	close(done)
+	for r := 0; r < 4; r++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// format_versionを持たない初期レイアウト（testdata/v0）の読み込みと移行
+func TestLoadV0Directory(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"metadata.json", "readings.json", "users.json"} {
+		data, err := os.ReadFile(filepath.Join("testdata", "v0", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := LoadDatabaseAt("legacy", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewSQLParser(db)
+
+	// 別名の型は正規の型名になり、値はカラムの型に変換される
+	columns := mustExec(t, p, "SHOW COLUMNS FROM readings")
+	assertValues(t, columns, "type", "INTEGER", "VARCHAR", "FLOAT", "DECIMAL", "DATETIME")
+	result := mustExec(t, p, "SELECT * FROM readings ORDER BY id")
+	assertValues(t, result, "value", "3", "2.5")
+	assertValues(t, result, "price", "12.5", "7.0")
+	assertValues(t, result, "taken_at", "2024-01-02 03:04:05", "NULL")
+	assertValues(t, result, "label", "a label longer than any size limit", "NULL")
+	assertValues(t, mustExec(t, p, "SELECT id FROM readings WHERE value > 2.9"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT name FROM users WHERE active = TRUE"), "name", "Alice")
+	mustFail(t, p, "INSERT INTO users VALUES (1, 'dup', FALSE)", "duplicate primary key value: 1")
+
+	// 移行後は現在のフォーマットで保存される
+	data, err := os.ReadFile(filepath.Join(dir, "metadata.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf(`"format_version":%d`, StorageFormatVersion); !strings.Contains(string(data), want) {
+		t.Fatalf("metadata.json does not contain %s: %s", want, data)
+	}
+	if _, err := LoadDatabaseAt("legacy", dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroupByRollup(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE sales (region VARCHAR(10), product VARCHAR(10), amount INTEGER)")
+	for _, row := range []string{
+		"('east', 'a', 10)", "('west', 'a', 5)", "('east', 'b', 20)", "('east', 'a', 1)", "('west', 'b', 7)",
+	} {
+		mustExec(t, p, "INSERT INTO sales VALUES "+row)
+	}
+
+	// 地域ごとの行の後に総計（regionはNULL）
+	result := mustExec(t, p, "SELECT region, COUNT(*), SUM(amount) FROM sales GROUP BY ROLLUP(region) ORDER BY region")
+	assertValues(t, result, "region", "east", "west", "NULL")
+	assertValues(t, result, "COUNT(*)", "3", "2", "5")
+	assertValues(t, result, "SUM(amount)", "31", "12", "43")
+
+	// 2カラム: 明細、地域ごとの小計、総計
+	result = mustExec(t, p, "SELECT region, product, SUM(amount) AS total FROM sales GROUP BY ROLLUP(region, product) ORDER BY region, product")
+	assertValues(t, result, "region", "east", "east", "east", "west", "west", "west", "NULL")
+	assertValues(t, result, "product", "a", "b", "NULL", "a", "b", "NULL", "NULL")
+	assertValues(t, result, "total", "11", "20", "31", "5", "7", "12", "43")
+
+	// 行がなくても総計の行は返る
+	result = mustExec(t, p, "SELECT region, COUNT(*) FROM sales WHERE amount > 100 GROUP BY ROLLUP(region)")
+	assertValues(t, result, "region", "NULL")
+	assertValues(t, result, "COUNT(*)", "0")
+
+	mustFail(t, p, "SELECT region, product, COUNT(*) FROM sales GROUP BY ROLLUP(region)", "must appear in the GROUP BY clause")
+	mustFail(t, p, "SELECT COUNT(*) FROM sales GROUP BY ROLLUP(", "missing ')'")
+}
+
+func TestOrderByAggregate(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (g VARCHAR(10), v INTEGER)")
+	for _, row := range []string{"('a', 1)", "('b', 5)", "('b', 2)", "('c', 9)", "('c', 1)", "('c', 3)"} {
+		mustExec(t, p, "INSERT INTO t VALUES "+row)
+	}
+
+	// 集約関数の別名
+	result := mustExec(t, p, "SELECT g, COUNT(*) AS c FROM t GROUP BY g ORDER BY c DESC")
+	assertValues(t, result, "g", "c", "b", "a")
+	assertValues(t, result, "c", "3", "2", "1")
+
+	// 集約関数そのもの（小文字・修飾付きでもよい）
+	result = mustExec(t, p, "SELECT g, SUM(v) FROM t GROUP BY g ORDER BY sum(t.v)")
+	assertValues(t, result, "g", "a", "b", "c")
+	assertValues(t, mustExec(t, p, "SELECT g FROM t GROUP BY g ORDER BY COUNT(*) DESC, g"), "g", "c", "b", "a")
+
+	// SELECTリストにない集約関数・グループ化カラムは結果に含めない
+	result = mustExec(t, p, "SELECT COUNT(*) FROM t GROUP BY g ORDER BY MAX(v), g DESC")
+	assertValues(t, result, "COUNT(*)", "1", "2", "3")
+	for _, row := range result.Rows {
+		if len(row) != 1 {
+			t.Fatalf("unexpected columns in %v", row)
+		}
+	}
+
+	mustFail(t, p, "SELECT g, COUNT(*) FROM t GROUP BY g ORDER BY v", "ORDER BY column 'v' must appear in the GROUP BY clause")
+	mustFail(t, p, "SELECT g FROM t GROUP BY g ORDER BY SUM(x)", "column 'x' does not exist")
+}
+
+func TestReindex(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, v INTEGER)")
+	mustExec(t, p, "CREATE INDEX idx_v ON t (v)")
+	for i := 1; i <= 5; i++ {
+		mustExec(t, p, "INSERT INTO t VALUES (?, ?)", i, i%2)
+	}
+
+	// メモリ上のインデックスを壊すと、インデックスを使う検索と重複チェックが誤る
+	table := db.Tables["t"]
+	table.Indexes[0].entries = map[string][]int{}
+	table.keys = map[string]*Index{"id": {Column: "id", entries: map[string][]int{}}}
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v = 1"), "id")
+
+	mustExec(t, p, "REINDEX TABLE t")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v = 1 ORDER BY id"), "id", "1", "3", "5")
+	mustFail(t, p, "INSERT INTO t VALUES (2, 0)", "duplicate primary key value: 2")
+
+	table.Indexes[0].entries = map[string][]int{}
+	if err := db.Reindex(""); err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v = 0 ORDER BY id"), "id", "2", "4")
+
+	mustExec(t, p, "REINDEX;")
+	mustFail(t, p, "REINDEX TABLE missing", "table 'missing' does not exist")
+	mustFail(t, p, "REINDEX TABLE t extra", "invalid REINDEX syntax")
+}
+
+func TestInsertValueCount(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10), age INTEGER)")
+
+	mustFail(t, p, "INSERT INTO t VALUES (1, 'a')", "too few values: expected 3, got 2")
+	mustFail(t, p, "INSERT INTO t VALUES (1, 'a', 2, 3)", "too many values: expected 3, got 4")
+	mustFail(t, p, "INSERT INTO t (id, name) VALUES (3)", "too few values: expected 2, got 1")
+	mustFail(t, p, "INSERT INTO t (id) VALUES (3, 'x')", "too many values: expected 1, got 2")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t"), "id")
+
+	// 指定しなかったカラムはNULL
+	mustExec(t, p, "INSERT INTO t (id, name) VALUES (3, 'c')")
+	assertValues(t, mustExec(t, p, "SELECT age FROM t"), "age", "NULL")
+
+	// 値が足りないカラム名を示す
+	mustFail(t, p, "INSERT INTO t VALUES (4)", "too few values: expected 3, got 1 (no value for name, age)")
+	mustFail(t, p, "INSERT INTO t (age, id) VALUES (30)", "too few values: expected 2, got 1 (no value for id)")
+	mustFail(t, p, "INSERT INTO t () VALUES ()", "too few values: expected 3, got 0")
+
+	// カラムリストの不備
+	mustFail(t, p, "INSERT INTO t (id, id) VALUES (4, 5)", "duplicate column 'id' in INSERT")
+	mustFail(t, p, "INSERT INTO t (id, name VALUES (4, 'd')", "missing ')' in INSERT column list")
+	mustFail(t, p, "INSERT INTO t (id) extra VALUES (4)", "expected VALUES after column list")
+	mustFail(t, p, "INSERT INTO t extra VALUES (4, 'd', 1)", "expected column list or VALUES after table name")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t"), "id", "3")
+}
+
+func TestInsertUnknownColumn(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10))")
+
+	err := db.Table("t").Insert(map[string]interface{}{"id": 1, "nmae": "typo"})
+	if err == nil || !strings.Contains(err.Error(), "column 'nmae' does not exist") {
+		t.Fatalf("expected unknown column error, got %v", err)
+	}
+	if err := db.Insert("t", map[string]interface{}{"id": 1, "extra": 0}); err == nil {
+		t.Fatal("expected unknown column error from Insert")
+	}
+	mustFail(t, p, "INSERT INTO t (id, nmae) VALUES (1, 'typo')", "column 'nmae' does not exist")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t"), "id")
+}
+
+// 保存するJSONのコンパクト形式と整形の比較（1万行のテーブルの保存）
+func BenchmarkSaveFormat(b *testing.B) {
+	for _, indent := range []string{"off", "on"} {
+		b.Run("indent="+indent, func(b *testing.B) {
+			dir := b.TempDir()
+			db := NewDatabaseAt("bench", dir)
+			p := NewSQLParser(db)
+			mustExec(b, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(20), score FLOAT, active BOOLEAN)")
+			mustExec(b, p, "SET autosave = off")
+			for i := 0; i < 10000; i++ {
+				mustExec(b, p, "INSERT INTO t VALUES (?, ?, ?, ?)", i, fmt.Sprintf("user%d", i), float64(i)/3, i%2 == 0)
+			}
+			mustExec(b, p, "SET storage_indent = "+indent)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := db.Save(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			info, err := os.Stat(filepath.Join(dir, "t.json"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(info.Size()), "file-bytes")
+		})
+	}
+}
+
+func TestUpdateFrom(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE orders (id INTEGER PRIMARY KEY, status VARCHAR(10))")
+	mustExec(t, p, "CREATE TABLE shipments (id INTEGER PRIMARY KEY, order_id INTEGER, done BOOLEAN)")
+	for i := 1; i <= 4; i++ {
+		mustExec(t, p, "INSERT INTO orders VALUES (?, 'new')", i)
+	}
+	mustExec(t, p, "INSERT INTO shipments VALUES (10, 1, TRUE)")
+	mustExec(t, p, "INSERT INTO shipments VALUES (11, 2, FALSE)")
+	mustExec(t, p, "INSERT INTO shipments VALUES (12, 3, TRUE)")
+	mustExec(t, p, "INSERT INTO shipments VALUES (13, 3, TRUE)")
+
+	// 一致する行だけを更新し、複数の行に一致しても1回だけ数える
+	result := mustExec(t, p, "UPDATE orders SET status = 'shipped' FROM shipments WHERE orders.id = shipments.order_id AND shipments.done = TRUE")
+	if result.Message != "2 row(s) updated" {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	assertValues(t, mustExec(t, p, "SELECT status FROM orders ORDER BY id"), "status", "shipped", "new", "shipped", "new")
+
+	// 一方のテーブルにしかないカラムは修飾しなくてよい（右辺のカラムは修飾が必要）
+	mustExec(t, p, "UPDATE orders SET orders.status = 'waiting' FROM shipments WHERE order_id = orders.id AND done = FALSE")
+	assertValues(t, mustExec(t, p, "SELECT status FROM orders ORDER BY id"), "status", "shipped", "waiting", "shipped", "new")
+
+	// 一致する行がなければ何も更新しない
+	result = mustExec(t, p, "UPDATE orders SET status = 'x' FROM shipments WHERE orders.id = shipments.order_id AND shipments.id > 100")
+	if result.Message != "0 row(s) updated" {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+
+	mustFail(t, p, "UPDATE orders SET status = 'x' FROM shipments WHERE id = 1", "column 'id' is ambiguous")
+	mustFail(t, p, "UPDATE orders SET status = 'x' FROM missing WHERE orders.id = 1", "table 'missing' does not exist")
+	mustFail(t, p, "UPDATE orders SET status = 'x' FROM orders WHERE id = 1", "with itself")
+	mustFail(t, p, "UPDATE orders SET status = 'x' FROM shipments WHERE orders.id = shipments.nope", "column 'shipments.nope' does not exist")
+	mustFail(t, p, "UPDATE orders SET status = 'x' FROM shipments WHERE orders.id = shipments.order_id RETURNING id", "not supported")
+}
+
+func TestColumnComparison(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, lo INTEGER, hi INTEGER)")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 1, 5)")
+	mustExec(t, p, "INSERT INTO t VALUES (2, 7, 3)")
+	mustExec(t, p, "INSERT INTO t VALUES (3, 4, 4)")
+
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE t.lo < t.hi"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t x WHERE x.lo >= x.hi ORDER BY id"), "id", "2", "3")
+	// 引用符で囲んだ値はカラムではなく文字列
+	mustFail(t, p, "SELECT id FROM t WHERE id = 't.lo'", "cannot compare INTEGER column 'id' with 't.lo'")
+}
+
+func TestSelectRejectsLeftoverTokens(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, g INTEGER)")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 1)")
+	mustExec(t, p, "INSERT INTO t VALUES (2, 1)")
+
+	mustFail(t, p, "SELECT * FROM t LIMIT 1 WHERE id = 2", "unexpected token in SELECT: WHERE")
+	mustFail(t, p, "SELECT * FROM t ORDER BY id WHERE id = 2", "unexpected token in SELECT: WHERE")
+	mustFail(t, p, "SELECT * FROM t WHERE id = 2 garbage here", "unexpected token in SELECT: garbage")
+	mustFail(t, p, "SELECT g, COUNT(*) FROM t GROUP BY g extra", "unexpected token in SELECT: extra")
+	mustFail(t, p, "SELECT * FROM t LIMIT", "missing LIMIT value")
+	mustFail(t, p, "SELECT * FROM t LIMIT 1 OFFSET", "missing OFFSET value")
+	mustFail(t, p, "SELECT * FROM t;;", "unexpected token in SELECT: ;")
+
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE g = 1 ORDER BY id DESC LIMIT 1 OFFSET 1;"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t OFFSET 1 LIMIT 5"), "id", "2")
+}
+
+func TestDeleteAndUpdateRejectLeftoverTokens(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10))")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 'a')")
+	mustExec(t, p, "INSERT INTO t VALUES (2, 'b')")
+
+	mustFail(t, p, "DELETE FROM t x WHERE id = 1", "unexpected token in DELETE: x")
+	mustFail(t, p, "DELETE FROM t WHERE id = 1 garbage", "unexpected token in DELETE: garbage")
+	mustFail(t, p, "UPDATE t SET name = 'z' WHERE id = 1 garbage", "unexpected token in UPDATE: garbage")
+	mustFail(t, p, "UPDATE t SET name = 'z' id = 2", "unexpected token in UPDATE: id")
+	mustFail(t, p, "UPDATE t SET name = 'z',", "invalid SET syntax")
+	assertValues(t, mustExec(t, p, "SELECT name FROM t ORDER BY id"), "name", "a", "b")
+
+	// 末尾の ; は許す
+	mustExec(t, p, "UPDATE t SET name = 'z', id = 3 WHERE id = 2;")
+	mustExec(t, p, "DELETE FROM t WHERE id = 1;")
+	assertValues(t, mustExec(t, p, "DELETE FROM t WHERE id = 3 RETURNING name;"), "name", "z")
+}
+
+// JSONから読み込んだキー（float64）と新しい値（int・float64・文字列）を同じ値として比較する
+func TestDuplicateKeyAfterReload(t *testing.T) {
+	dir := t.TempDir()
+	p := NewSQLParser(NewDatabaseAt("test", dir))
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, code INTEGER UNIQUE, name VARCHAR(10))")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 100, 'a')")
+	mustExec(t, p, "INSERT INTO t VALUES (2, 200, 'b')")
+
+	db, err := LoadDatabaseAt("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.Tables["t"].Rows[0]["id"].(float64); !ok {
+		t.Fatalf("expected keys loaded from JSON as float64, got %T", db.Tables["t"].Rows[0]["id"])
+	}
+	p = NewSQLParser(db)
+
+	mustFail(t, p, "INSERT INTO t VALUES (1, 101, 'x')", "duplicate primary key value: 1")
+	mustFail(t, p, "INSERT INTO t VALUES (1.0, 101, 'x')", "duplicate primary key value: 1")
+	mustFail(t, p, "INSERT INTO t VALUES ('2', 101, 'x')", "duplicate primary key value: 2")
+	mustFail(t, p, "INSERT INTO t VALUES (3, 200, 'x')", "UNIQUE column 'code'")
+	mustFail(t, p, "UPDATE t SET id = 2 WHERE id = 1", "duplicate primary key value: 2")
+	if err := db.Insert("t", map[string]interface{}{"id": 1.0, "code": 300}); err == nil {
+		t.Fatal("expected duplicate primary key error from Insert")
+	}
+
+	mustExec(t, p, "INSERT INTO t VALUES (3, 300, 'c')")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t ORDER BY id"), "id", "1", "2", "3")
+}
+
+func TestIndexFollowsDML(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, v INTEGER)")
+	mustExec(t, p, "CREATE INDEX idx_v ON t (v)")
+	for i := 1; i <= 6; i++ {
+		mustExec(t, p, "INSERT INTO t VALUES (?, ?)", i, i%3)
+	}
+
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v = 0 ORDER BY id"), "id", "3", "6")
+	mustExec(t, p, "UPDATE t SET v = 0 WHERE id = 1")
+	mustExec(t, p, "DELETE FROM t WHERE v = 0 AND id = 3")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v = 0 ORDER BY id"), "id", "1", "6")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v = 1 ORDER BY id"), "id", "4")
+	mustExec(t, p, "UPDATE t SET v = 5 WHERE v = 0")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v = '5' ORDER BY id"), "id", "1", "6")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v = 0"), "id")
+}
+
+// インデックスのあるカラムとないカラムの等価検索（10万行）
+func BenchmarkIndexLookup(b *testing.B) {
+	for _, indexed := range []bool{true, false} {
+		b.Run(fmt.Sprintf("indexed=%v", indexed), func(b *testing.B) {
+			db, p := newTestDB(b)
+			mustExec(b, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, v INTEGER)")
+			if indexed {
+				mustExec(b, p, "CREATE INDEX idx_v ON t (v)")
+			}
+			for i := 0; i < 100000; i++ {
+				if err := db.Insert("t", map[string]interface{}{"id": i, "v": i}); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				result := mustExec(b, p, "SELECT id FROM t WHERE v = ?", i%100000)
+				if len(result.Rows) != 1 {
+					b.Fatalf("got %d rows", len(result.Rows))
+				}
+			}
+		})
+	}
+}
+
+func TestTruncateResetsAutoIncrement(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY AUTO_INCREMENT, name VARCHAR(10))")
+	mustExec(t, p, "INSERT INTO t (name) VALUES ('a')")
+	mustExec(t, p, "INSERT INTO t (name) VALUES ('b')")
+
+	mustExec(t, p, "TRUNCATE TABLE t")
+	result := mustExec(t, p, "INSERT INTO t (name) VALUES ('c')")
+	if result.Message != "1 row inserted (id = 1)" {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	assertValues(t, mustExec(t, p, "SELECT id FROM t"), "id", "1")
+
+	// テーブル名の後の余分なトークンはエラーで、行は残る
+	mustFail(t, p, "TRUNCATE TABLE t WHERE id = 1", "unexpected token in TRUNCATE: WHERE")
+	mustFail(t, p, "TRUNCATE t, u", "unexpected token in TRUNCATE: ,")
+	mustFail(t, p, "TRUNCATE TABLE", "missing table name")
+	mustFail(t, p, "TRUNCATE TABLE missing", "does not exist")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t"), "id", "1")
+	mustExec(t, p, "TRUNCATE t;")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t"), "id")
+}
+
+// 1万行のINSERT（文ごとの保存、トランザクション、autosave = offの比較）。
+// 文ごとの保存は毎回テーブル全体を書き出すため行数の2乗に比例し、1回に2分近くかかる
+func BenchmarkInsert10kRows(b *testing.B) {
+	modes := []struct {
+		name       string
+		begin, end string
+	}{
+		{"per-statement", "", ""},
+		{"transaction", "BEGIN", "COMMIT"},
+		{"autosave-off", "SET autosave = off", "SET autosave = on"},
+	}
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				p := NewSQLParser(NewDatabaseAt("bench", b.TempDir()))
+				mustExec(b, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(20))")
+				b.StartTimer()
+
+				if mode.begin != "" {
+					mustExec(b, p, mode.begin)
+				}
+				for j := 0; j < 10000; j++ {
+					mustExec(b, p, "INSERT INTO t VALUES (?, ?)", j, "x")
+				}
+				if mode.end != "" {
+					mustExec(b, p, mode.end)
+				}
+			}
+		})
+	}
+}
+
+// 複数のgoroutineからの同時アクセス（go test -race で競合がないことを確認する）
+func TestConcurrentAccess(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, worker INTEGER, v INTEGER)")
+	mustExec(t, p, "CREATE INDEX idx_worker ON t (worker)")
+
+	const workers, perWorker = 8, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*2)
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		// 書き込み: SQLParserとDatabaseのメソッドを交互に使う
+		go func() {
+			defer wg.Done()
+			wp := NewSQLParser(db)
+			for i := 0; i < perWorker; i++ {
+				id := w*perWorker + i
+				var err error
+				if i%2 == 0 {
+					_, err = wp.Exec("INSERT INTO t VALUES (?, ?, ?)", id, w, i)
+				} else {
+					err = db.Insert("t", map[string]interface{}{"id": id, "worker": w, "v": i})
+				}
+				if err == nil {
+					_, err = wp.Exec("UPDATE t SET v = ? WHERE id = ?", i*10, id)
+				}
+				if err == nil && i%5 == 0 {
+					_, err = wp.Exec("DELETE FROM t WHERE id = ?", id)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		// 読み取り
+		go func() {
+			defer wg.Done()
+			rp := NewSQLParser(db)
+			for i := 0; i < perWorker; i++ {
+				if _, err := rp.Exec("SELECT worker, COUNT(*), SUM(v) FROM t WHERE worker = ? GROUP BY worker", w); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := db.Query("SELECT * FROM t WHERE v >= ? ORDER BY id LIMIT 5", i); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// 各workerの行は5件に1件が削除され、vはすべて更新済み
+	result := mustExec(t, p, "SELECT worker, COUNT(*), MIN(v) FROM t GROUP BY worker ORDER BY worker")
+	if len(result.Rows) != workers {
+		t.Fatalf("got %d workers, want %d", len(result.Rows), workers)
+	}
+	for _, row := range result.Rows {
+		if row["COUNT(*)"] != perWorker-perWorker/5 || row["MIN(v)"] != 10 {
+			t.Fatalf("unexpected row %v", row)
+		}
+	}
+}
+
+func TestLoadDatabaseDirectoryError(t *testing.T) {
+	// 通常のファイルの下にはディレクトリを作れない
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadDatabaseAt("test", filepath.Join(file, "db"))
+	if err == nil || !strings.Contains(err.Error(), "failed to create database directory") {
+		t.Fatalf("expected directory error, got %v", err)
+	}
+}
+
+func TestInterruptedWrite(t *testing.T) {
+	dir := t.TempDir()
+	p := NewSQLParser(NewDatabaseAt("test", dir))
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, v VARCHAR(10))")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 'a')")
+	mustExec(t, p, "INSERT INTO t VALUES (2, 'b')")
+	tablePath := filepath.Join(dir, "t.json")
+
+	// 書き込み途中で止まった一時ファイルが残っていても、元のファイルから読み込める
+	if err := os.WriteFile(tablePath+".tmp", []byte(`[{"id": 3, "v": "c"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := LoadDatabaseAt("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = NewSQLParser(db)
+	assertValues(t, mustExec(t, p, "SELECT id FROM t ORDER BY id"), "id", "1", "2")
+
+	// 残った一時ファイルは次の保存で上書きされる
+	mustExec(t, p, "INSERT INTO t VALUES (3, 'c')")
+	if _, err := os.Stat(tablePath + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+
+	// 一時ファイルを作れず保存に失敗しても、元のファイルは変更されない
+	before, err := os.ReadFile(tablePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(tablePath+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ParseArgs("INSERT INTO t VALUES (4, 'd')"); err == nil {
+		t.Fatal("expected the save to fail")
+	}
+	after, err := os.ReadFile(tablePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Fatalf("table file changed by a failed save:\n%s", after)
+	}
+	if err := os.Remove(tablePath + ".tmp"); err != nil {
+		t.Fatal(err)
+	}
+	db, err = LoadDatabaseAt("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, mustExec(t, NewSQLParser(db), "SELECT id FROM t ORDER BY id"), "id", "1", "2", "3")
+}
+
+func TestLoadCorruptTableFile(t *testing.T) {
+	dir := t.TempDir()
+	p := NewSQLParser(NewDatabaseAt("test", dir))
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	mustExec(t, p, "INSERT INTO t VALUES (1)")
+
+	// 壊れたデータファイルは空のテーブルとして読み込まず、エラーにする（ファイルも変更しない）
+	tablePath := filepath.Join(dir, "t.json")
+	corrupt := []byte(`[{"id": 1}, {"id"`)
+	if err := os.WriteFile(tablePath, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadDatabaseAt("test", dir)
+	if err == nil || !strings.Contains(err.Error(), "failed to load table 't'") {
+		t.Fatalf("expected load error, got %v", err)
+	}
+	if data, _ := os.ReadFile(tablePath); string(data) != string(corrupt) {
+		t.Fatalf("corrupt table file was overwritten: %s", data)
+	}
+
+	// データファイルがないテーブルは空として読み込む
+	if err := os.Remove(tablePath); err != nil {
+		t.Fatal(err)
+	}
+	db, err := LoadDatabaseAt("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, mustExec(t, NewSQLParser(db), "SELECT id FROM t"), "id")
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	dir := t.TempDir()
+	p := NewSQLParser(NewDatabaseAt("test", dir))
+	mustExec(t, p, "CREATE TABLE enroll (student_id INTEGER, course_id INTEGER, grade VARCHAR(2), PRIMARY KEY (student_id, course_id))")
+	mustExec(t, p, "INSERT INTO enroll VALUES (1, 10, 'A')")
+	mustExec(t, p, "INSERT INTO enroll VALUES (1, 11, 'B')")
+	mustExec(t, p, "INSERT INTO enroll VALUES (2, 10, 'C')")
+
+	// キーの全カラムが一致する場合のみ重複
+	mustFail(t, p, "INSERT INTO enroll VALUES (1, 10, 'D')", "duplicate primary key value: (1, 10)")
+	mustFail(t, p, "INSERT INTO enroll (student_id, grade) VALUES (3, 'A')", "column 'course_id' cannot be null")
+	mustFail(t, p, "UPDATE enroll SET course_id = 11 WHERE student_id = 1", "duplicate primary key value")
+	mustFail(t, p, "UPDATE enroll SET course_id = 10 WHERE student_id = 1 AND course_id = 11", "duplicate primary key value: (1, 10)")
+	mustExec(t, p, "UPDATE enroll SET course_id = 12 WHERE student_id = 1 AND course_id = 11")
+	mustExec(t, p, "DELETE FROM enroll WHERE student_id = 2")
+	mustExec(t, p, "INSERT INTO enroll VALUES (2, 10, 'A')")
+
+	// 保存・読み込み後もキーが維持される
+	db, err := LoadDatabaseAt("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = NewSQLParser(db)
+	mustFail(t, p, "INSERT INTO enroll VALUES (1, 12, 'A')", "duplicate primary key value: (1, 12)")
+	result := mustExec(t, p, "SHOW CREATE TABLE enroll")
+	if stmt := fmt.Sprint(result.Rows[0]["create_statement"]); !strings.Contains(stmt, "PRIMARY KEY (student_id, course_id)") {
+		t.Fatalf("composite key missing from %s", stmt)
+	}
+	assertValues(t, mustExec(t, p, "SHOW COLUMNS FROM enroll"), "primary", "true", "true", "false")
+	mustFail(t, p, "ALTER TABLE enroll DROP COLUMN course_id", "cannot drop primary key column 'course_id'")
+	mustFail(t, p, "ALTER TABLE enroll ADD COLUMN id INTEGER PRIMARY KEY", "multiple primary keys defined")
+
+	// 1カラムだけの表制約はカラムの主キーと同じ
+	mustExec(t, p, "CREATE TABLE single (id INTEGER, PRIMARY KEY (id))")
+	mustExec(t, p, "INSERT INTO single VALUES (1)")
+	mustFail(t, p, "INSERT INTO single VALUES (1)", "duplicate primary key value: 1")
+
+	mustFail(t, p, "CREATE TABLE bad (a INTEGER PRIMARY KEY, b INTEGER, PRIMARY KEY (a, b))", "multiple primary keys defined")
+	mustFail(t, p, "CREATE TABLE bad (a INTEGER, PRIMARY KEY (a, c))", "primary key column 'c' does not exist")
+	mustFail(t, p, "CREATE TABLE bad (a INTEGER, PRIMARY KEY (a), PRIMARY KEY (a))", "multiple primary keys defined")
+}
+
+func TestSelectArithmeticExpressions(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE line_items (id INTEGER, price INTEGER, quantity INTEGER, name VARCHAR(10))")
+	mustExec(t, p, "INSERT INTO line_items VALUES (1, 3, 4, 'a')")
+	mustExec(t, p, "INSERT INTO line_items VALUES (2, 10, 2, 'b')")
+	mustExec(t, p, "INSERT INTO line_items VALUES (3, 5, NULL, 'c')")
+
+	result := mustExec(t, p, "SELECT id, price * quantity AS total FROM line_items ORDER BY total")
+	assertValues(t, result, "id", "1", "2", "3")
+	assertValues(t, result, "total", "12", "20", "NULL")
+
+	// 優先順位・括弧・リテラル・修飾
+	result = mustExec(t, p, "SELECT 1 + li.price * 2, (1 + price) * 2, price / 2, price - -1 FROM line_items li WHERE id = 1")
+	assertValues(t, result, "1 + li.price * 2", "7")
+	assertValues(t, result, "(1 + price) * 2", "8")
+	assertValues(t, result, "price / 2", "1.5")
+	assertValues(t, result, "price - -1", "4")
+
+	// GROUP BYのカラムのみを参照する式
+	result = mustExec(t, p, "SELECT price * 10 AS p, COUNT(*) FROM line_items GROUP BY price ORDER BY p DESC")
+	assertValues(t, result, "p", "100", "50", "30")
+
+	mustFail(t, p, "SELECT price / 0 FROM line_items", "division by zero")
+	mustFail(t, p, "SELECT name * 2 FROM line_items", "cannot apply '*' to non-numeric value 'a'")
+	mustFail(t, p, "SELECT price * missing FROM line_items", "column 'missing' does not exist")
+	mustFail(t, p, "SELECT price + FROM line_items", "missing operand")
+	mustFail(t, p, "SELECT (price + 1 FROM line_items", "missing ')'")
+	mustFail(t, p, "SELECT SUM(price) * 2 FROM line_items", "aggregate functions cannot be used in expressions")
+	mustFail(t, p, "SELECT quantity * 2, COUNT(*) FROM line_items GROUP BY price", "column 'quantity' must appear in the GROUP BY clause")
+}
+
+func TestScalarFunctions(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE u (id INTEGER, name VARCHAR(10), bal FLOAT)")
+	mustExec(t, p, "INSERT INTO u VALUES (1, 'Alice', -2.345)")
+	mustExec(t, p, "INSERT INTO u VALUES (2, 'bob', 10.5)")
+	mustExec(t, p, "INSERT INTO u VALUES (3, NULL, NULL)")
+
+	result := mustExec(t, p, "SELECT UPPER(name), lower(name) AS l, LENGTH(name), ABS(bal), ROUND(bal, 1), ROUND(bal), ABS(id - 5) FROM u ORDER BY id")
+	assertValues(t, result, "UPPER(name)", "ALICE", "BOB", "NULL")
+	assertValues(t, result, "l", "alice", "bob", "NULL")
+	assertValues(t, result, "LENGTH(name)", "5", "3", "NULL")
+	assertValues(t, result, "ABS(bal)", "2.345", "10.5", "NULL")
+	assertValues(t, result, "ROUND(bal, 1)", "-2.3", "10.5", "NULL")
+	assertValues(t, result, "ROUND(bal)", "-2", "11", "NULL")
+	assertValues(t, result, "ABS(id - 5)", "4", "3", "2")
+
+	// WHEREの左辺（式と組み合わせ、DELETEでも使える）
+	assertValues(t, mustExec(t, p, "SELECT id FROM u WHERE UPPER(name) = 'BOB'"), "id", "2")
+	assertValues(t, mustExec(t, p, "SELECT id FROM u WHERE LENGTH(name) > 3"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM u WHERE ABS(bal) + 1 BETWEEN 3 AND 4 OR UPPER(name) IS NULL ORDER BY id"), "id", "1", "3")
+	assertValues(t, mustExec(t, p, "SELECT id FROM u x WHERE LOWER(x.name) IN ('alice', 'bob') ORDER BY id"), "id", "1", "2")
+	mustExec(t, p, "DELETE FROM u WHERE LOWER(name) = 'alice'")
+	assertValues(t, mustExec(t, p, "SELECT id FROM u ORDER BY id"), "id", "2", "3")
+
+	mustFail(t, p, "SELECT FOO(name) FROM u", "unknown function 'FOO'")
+	mustFail(t, p, "SELECT id FROM u WHERE BAR(name) = 1", "unknown function 'BAR'")
+	mustFail(t, p, "SELECT ROUND(bal, 1, 2) FROM u", "wrong number of arguments for ROUND: 3")
+	mustFail(t, p, "SELECT ABS(name) FROM u", "ABS requires a numeric argument")
+	mustFail(t, p, "SELECT UPPER(missing) FROM u", "column 'missing' does not exist")
+	mustFail(t, p, "SELECT UPPER(name FROM u", "missing ')' in UPPER")
+}
+
+func TestSelectExecutionOrder(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE emp (name VARCHAR(10), dept VARCHAR(10), salary INTEGER, active BOOLEAN)")
+	for _, row := range []string{
+		"('a', 'dev', 100, TRUE)", "('b', 'dev', 200, TRUE)", "('c', 'dev', 9000, FALSE)",
+		"('d', 'ops', 50, TRUE)", "('e', 'ops', 1, FALSE)", "('f', 'ops', 1, FALSE)",
+		"('g', 'hr', 70, FALSE)",
+	} {
+		mustExec(t, p, "INSERT INTO emp VALUES "+row)
+	}
+
+	// WHERE → グループ化・集約（非アクティブな社員は平均にも件数にも含まれず、hrのグループはできない）
+	result := mustExec(t, p, "SELECT dept, AVG(salary), COUNT(*) FROM emp WHERE active = TRUE GROUP BY dept ORDER BY dept")
+	assertValues(t, result, "dept", "dev", "ops")
+	assertValues(t, result, "AVG(salary)", "150", "50")
+	assertValues(t, result, "COUNT(*)", "2", "1")
+
+	// 集約 → ORDER BY → LIMIT / OFFSET（並べ替えとLIMITは集約した行に適用される）
+	result = mustExec(t, p, "SELECT dept, COUNT(*) AS n FROM emp GROUP BY dept ORDER BY n DESC, dept LIMIT 2")
+	assertValues(t, result, "dept", "dev", "ops")
+	result = mustExec(t, p, "SELECT dept, SUM(salary) AS total FROM emp WHERE active = TRUE GROUP BY dept ORDER BY total LIMIT 1 OFFSET 1")
+	assertValues(t, result, "dept", "dev")
+	assertValues(t, result, "total", "300")
+
+	// 集約なし: WHERE → ORDER BY → DISTINCT → LIMIT
+	result = mustExec(t, p, "SELECT DISTINCT dept FROM emp WHERE salary < 1000 ORDER BY dept DESC LIMIT 2")
+	assertValues(t, result, "dept", "ops", "hr")
+}
+
+func TestInsertOrReplaceAndIgnore(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(10), email VARCHAR(20) UNIQUE)")
+	mustExec(t, p, "INSERT INTO users VALUES (1, 'alice', 'a@x')")
+	mustExec(t, p, "INSERT INTO users VALUES (2, 'bob', 'b@x')")
+
+	if result := mustExec(t, p, "INSERT OR REPLACE INTO users VALUES (1, 'alicia', 'a2@x')"); result.Message != "1 row replaced" {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	if result := mustExec(t, p, "INSERT OR REPLACE INTO users (id, name) VALUES (3, 'carol')"); result.Message != "1 row inserted" {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	if result := mustExec(t, p, "INSERT OR IGNORE INTO users VALUES (2, 'robert', 'r@x')"); !strings.Contains(result.Message, "0 rows inserted") {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	result := mustExec(t, p, "SELECT id, name, email FROM users ORDER BY id")
+	assertValues(t, result, "name", "alicia", "bob", "carol")
+	assertValues(t, result, "email", "a2@x", "b@x", "NULL")
+
+	// 置き換えた行でも他の行とのUNIQUEの重複はエラー
+	mustFail(t, p, "INSERT OR REPLACE INTO users VALUES (1, 'x', 'b@x')", "duplicate value b@x for UNIQUE column 'email'")
+	mustFail(t, p, "INSERT OR IGNORE INTO users VALUES (9, 'x', 'b@x')", "duplicate value b@x for UNIQUE column 'email'")
+	mustFail(t, p, "INSERT OR UPDATE INTO users VALUES (1, 'x', 'y')", "expected REPLACE or IGNORE")
+
+	// 複数カラムの主キーとライブラリからの呼び出し
+	mustExec(t, p, "CREATE TABLE enroll (s INTEGER, c INTEGER, grade VARCHAR(2), PRIMARY KEY (s, c))")
+	mustExec(t, p, "INSERT INTO enroll VALUES (1, 1, 'B')")
+	conflict, err := db.InsertOr("enroll", map[string]interface{}{"s": 1, "c": 1, "grade": "A"}, ConflictReplace)
+	if err != nil || !conflict {
+		t.Fatalf("expected a replaced row, got %v, %v", conflict, err)
+	}
+	conflict, err = db.InsertOr("enroll", map[string]interface{}{"s": 1, "c": 2, "grade": "C"}, ConflictIgnore)
+	if err != nil || conflict {
+		t.Fatalf("expected an inserted row, got %v, %v", conflict, err)
+	}
+	assertValues(t, mustExec(t, p, "SELECT grade FROM enroll ORDER BY c"), "grade", "A", "C")
+}
+
+func TestMultiRowInsert(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE users (id INTEGER PRIMARY KEY AUTO_INCREMENT, name VARCHAR(10) NOT NULL)")
+
+	if result := mustExec(t, p, "INSERT INTO users (name) VALUES ('alice'), ('bob'), ('carol');"); result.Message != "3 rows inserted (last id = 3)" {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	assertValues(t, mustExec(t, p, "SELECT name FROM users ORDER BY id"), "name", "alice", "bob", "carol")
+
+	// 途中の行でエラーになると、それまでの行も挿入されない
+	mustFail(t, p, "INSERT INTO users VALUES (4, 'dave'), (5, 'eve'), (1, 'x')", "row 3: duplicate primary key")
+	mustFail(t, p, "INSERT INTO users VALUES (4, 'dave'), (5, NULL)", "row 2:")
+	assertValues(t, mustExec(t, p, "SELECT name FROM users ORDER BY id"), "name", "alice", "bob", "carol")
+	if id := db.LastInsertID(); id != 3 {
+		t.Fatalf("expected last insert id 3 after rollback, got %d", id)
+	}
+
+	// 値の数と構文のチェック
+	mustFail(t, p, "INSERT INTO users VALUES (4, 'dave'), (5)", "row 2: too few values: expected 2, got 1")
+	mustFail(t, p, "INSERT INTO users VALUES (4, 'dave'), (5, 'eve', 1)", "row 2: too many values: expected 2, got 3")
+	mustFail(t, p, "INSERT INTO users VALUES (4, 'dave'),", "expected '(' in VALUES clause")
+	mustFail(t, p, "INSERT INTO users VALUES (4, 'dave') (5, 'eve')", "unexpected")
+	mustFail(t, p, "INSERT INTO users VALUES (4, 'dave'", "missing ')' in VALUES clause")
+
+	// RETURNINGは挿入した全行を返す
+	result := mustExec(t, p, "INSERT INTO users (name) VALUES ('dave'), ('eve') RETURNING id, name")
+	assertValues(t, result, "id", "4", "5")
+	assertValues(t, result, "name", "dave", "eve")
+
+	// OR REPLACE / OR IGNOREと組み合わせる
+	if result := mustExec(t, p, "INSERT OR REPLACE INTO users VALUES (1, 'alicia'), (6, 'frank')"); result.Message != "1 row inserted (id = 6), 1 row replaced" {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	if result := mustExec(t, p, "INSERT OR IGNORE INTO users VALUES (2, 'x'), (3, 'y'), (7, 'grace')"); result.Message != "1 row inserted (id = 7) (2 duplicate primary keys ignored)" {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	result = mustExec(t, p, "INSERT OR REPLACE INTO users VALUES (2, 'bobby'), (8, 'heidi') RETURNING name")
+	assertValues(t, result, "name", "bobby", "heidi")
+	assertValues(t, mustExec(t, p, "SELECT name FROM users ORDER BY id"), "name", "alicia", "bobby", "carol", "dave", "eve", "frank", "grace", "heidi")
+
+	// ライブラリからの呼び出し
+	inserted, conflicts, err := db.InsertRows("users", []map[string]interface{}{{"name": "ivan"}, {"id": 1, "name": "x"}}, ConflictIgnore)
+	if err != nil || inserted != 1 || conflicts != 1 {
+		t.Fatalf("expected 1 inserted and 1 ignored, got %d, %d, %v", inserted, conflicts, err)
+	}
+}
+
+func TestSelectWithoutFrom(t *testing.T) {
+	db, p := newTestDB(t)
+
+	result := mustExec(t, p, "SELECT 1 + 2;")
+	assertValues(t, result, "1 + 2", "3")
+	result = mustExec(t, p, "SELECT UPPER('hi') AS greeting, 10 / 4, LENGTH('abc') * 2")
+	assertValues(t, result, "greeting", "HI")
+	assertValues(t, result, "10 / 4", "2.5")
+	assertValues(t, result, "LENGTH('abc') * 2", "6")
+	assertValues(t, mustExec(t, p, "SELECT COUNT(*)"), "COUNT(*)", "1")
+	if result := mustExec(t, p, "SELECT 5 LIMIT 0"); len(result.Rows) != 0 {
+		t.Fatalf("expected no rows, got %v", result.Rows)
+	}
+
+	mustFail(t, p, "SELECT *", "SELECT * requires a FROM clause")
+	mustFail(t, p, "SELECT name", "column 'name' does not exist")
+	mustFail(t, p, "SELECT 1 / 0", "division by zero")
+	mustFail(t, p, "SELECT 1 FROM", "missing table name")
+	mustFail(t, p, "SELECT 1 + 2 LIMIT 1 x", "unexpected token in SELECT")
+
+	// ライブラリからはTableを空にして呼び出す
+	expr, _, err := parseExpr([]string{"2", "*", "21"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = db.Select(&SelectQuery{Columns: []string{expr.String()}, Exprs: []*Expr{expr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, result, "2 * 21", "42")
+}
+
+func TestIgnoreCaseIdentifiers(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE users (id INTEGER PRIMARY KEY, Name VARCHAR(10))")
+	mustExec(t, p, "INSERT INTO users VALUES (1, 'alice')")
+
+	// デフォルトでは大文字・小文字を区別する
+	mustFail(t, p, "SELECT name FROM users", "column 'name' does not exist")
+	mustFail(t, p, "SELECT Name FROM USERS", "table 'USERS' does not exist")
+
+	mustExec(t, p, "SET ignore_case = on")
+	mustExec(t, p, "INSERT INTO USERS (ID, name) VALUES (2, 'bob')")
+	result := mustExec(t, p, "SELECT NAME, u.ID FROM Users u WHERE name = 'bob'")
+	if !slices.Equal(result.Columns, []string{"Name", "id"}) {
+		t.Fatalf("expected stored column names, got %v", result.Columns)
+	}
+	assertValues(t, result, "Name", "bob")
+	mustExec(t, p, "UPDATE USERS SET NAME = 'robert' WHERE Id = 2")
+	mustExec(t, p, "DELETE FROM Users WHERE ID = 1")
+	assertValues(t, mustExec(t, p, "SELECT name FROM users ORDER BY NAME"), "Name", "robert")
+	mustFail(t, p, "CREATE TABLE USERS (x INTEGER)", "table 'users' already exists")
+	mustFail(t, p, "SELECT missing FROM users", "column 'missing' does not exist")
+
+	mustExec(t, p, "SET ignore_case = off")
+	mustFail(t, p, "SELECT NAME FROM users", "column 'NAME' does not exist")
+}
+
+func TestQuotedIdentifiers(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE \"order\" (\"select\" INTEGER PRIMARY KEY, `first name` VARCHAR(10), \"from\" INTEGER)")
+	mustExec(t, p, "INSERT INTO \"order\" VALUES (1, 'a b', 5), (2, 'c', 7)")
+	mustExec(t, p, "UPDATE `order` SET \"first name\" = 'x' WHERE \"select\" = 2")
+
+	result := mustExec(t, p, "SELECT \"from\", \"select\" AS \"order\", o.\"first name\" FROM \"order\" o WHERE \"from\" > 4 ORDER BY \"select\" DESC")
+	if !slices.Equal(result.Columns, []string{"from", "order", "first name"}) {
+		t.Fatalf("unexpected columns %v", result.Columns)
+	}
+	assertValues(t, result, "order", "2", "1")
+	assertValues(t, result, "first name", "x", "a b")
+
+	mustFail(t, p, "SELECT \"\" FROM \"order\"", "empty quoted identifier")
+	mustFail(t, p, "SELECT \"select FROM \"order\"", "unterminated quoted identifier")
+
+	// DUMPはキーワードや空白を含む名前を引用符で囲み、そのまま再作成できる
+	var dump strings.Builder
+	if err := db.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dump.String(), "CREATE TABLE \"order\" (\"select\" INTEGER PRIMARY KEY, \"first name\" VARCHAR(10), \"from\" INTEGER);") {
+		t.Fatalf("unexpected dump:\n%s", dump.String())
+	}
+	_, restored := newTestDB(t)
+	if _, err := restored.ParseAll(dump.String()); err != nil {
+		t.Fatalf("failed to restore dump: %v\n%s", err, dump.String())
+	}
+	assertValues(t, mustExec(t, restored, "SELECT `first name` FROM \"order\" ORDER BY \"select\""), "first name", "a b", "x")
+}
+
+func TestUnknownDataType(t *testing.T) {
+	db, p := newTestDB(t)
+	mustFail(t, p, "CREATE TABLE v (a TEXTY)", "unknown data type 'TEXTY' for column 'a'")
+	mustFail(t, p, "CREATE TABLE v (id INTEGER, name strng)", "unknown data type 'strng' for column 'name'")
+	if _, exists := db.Tables["v"]; exists {
+		t.Fatal("table should not be created")
+	}
+
+	// 別名の型名は使える
+	mustExec(t, p, "CREATE TABLE v (a real, b DOUBLE, c TIMESTAMP, d NUMERIC(5,2), e varchar(3))")
+	result := mustExec(t, p, "SHOW COLUMNS FROM v")
+	assertValues(t, result, "type", "FLOAT", "FLOAT", "DATETIME", "DECIMAL", "VARCHAR")
+
+	mustFail(t, p, "ALTER TABLE v ADD COLUMN f BLOB", "unknown data type 'BLOB' for column 'f'")
+	if err := db.CreateTable("w", []Column{{Name: "x", Type: "FOO"}}); err == nil || !strings.Contains(err.Error(), "unknown data type 'FOO' for column 'x'") {
+		t.Fatalf("expected unknown data type error, got %v", err)
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, name VARCHAR(5))")
+
+	tests := []struct {
+		query   string
+		message string
+		token   string
+		pointer string
+	}{
+		{"SELECT * FROM t WHERE id = 2 garbage", "unexpected token in SELECT: garbage (position 30)", "garbage",
+			"SELECT * FROM t WHERE id = 2 garbage\n                             ^"},
+		{"UPDATE t SET name 'x' WHERE id = 1", "invalid SET syntax: expected '=' near 'x' (position 19)", "'x'",
+			"UPDATE t SET name 'x' WHERE id = 1\n                  ^"},
+		{"CREATE TABLE v (a TEXTY)", "unknown data type 'TEXTY' for column 'a' (position 19)", "TEXTY",
+			"CREATE TABLE v (a TEXTY)\n                  ^"},
+		{"DELETE t WHERE id = 1", "invalid DELETE syntax near 't' (position 8)", "t",
+			"DELETE t WHERE id = 1\n       ^"},
+		{"SELECT id FROM t ORDER BY", "missing ORDER BY column at end of statement (position 26)", "",
+			"SELECT id FROM t ORDER BY\n                         ^"},
+		{"INSERT INTO t\nVALUES (1, 'a') (2, 'b')", "unexpected token in INSERT: ( (position 31)", "(",
+			"VALUES (1, 'a') (2, 'b')\n                ^"},
+	}
+	for _, tt := range tests {
+		_, err := p.Exec(tt.query)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("%s: expected a syntax error, got %v", tt.query, err)
+		}
+		if err.Error() != tt.message {
+			t.Errorf("%s: got message %q, want %q", tt.query, err.Error(), tt.message)
+		}
+		if syntaxErr.Token != tt.token {
+			t.Errorf("%s: got token %q, want %q", tt.query, syntaxErr.Token, tt.token)
+		}
+		if pointer := syntaxErr.Pointer(); pointer != tt.pointer {
+			t.Errorf("%s: got pointer\n%s\nwant\n%s", tt.query, pointer, tt.pointer)
+		}
+	}
+
+	// 句の説明が付いた構文エラーも位置を持つ
+	if _, err := p.Exec("SELECT * FROM t WHERE id IN 1"); err == nil || err.Error() != "invalid IN list: expected '(' near '1' (position 29)" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// 複数の文ではエラーに行番号が付き、構文エラーとして取り出せる
+	_, err := p.ParseAll("SELECT * FROM t;\nSELECT * FROM t LIMIT x;")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || err.Error() != "line 2: invalid LIMIT value: x (position 23)" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// 構文エラー以外（存在しないテーブルなど）は位置を持たない
+	if _, err := p.Exec("SELECT * FROM missing"); errors.As(err, &syntaxErr) {
+		t.Fatalf("expected a plain error, got %v", err)
+	}
+}
+
+func TestTruncatedStatementsDoNotPanic(t *testing.T) {
+	statements := []string{
+		"CREATE TABLE t2 (id INTEGER PRIMARY KEY, name VARCHAR(10) NOT NULL DEFAULT 'x' CHECK (id > 0), d DECIMAL(5,2), FOREIGN KEY (id) REFERENCES t(id))",
+		"CREATE TABLE t4 (a INTEGER, b INTEGER, PRIMARY KEY (a, b))",
+		"CREATE INDEX idx ON t (name)",
+		"INSERT OR REPLACE INTO t (id, name) VALUES (1, 'a'), (2, 'b') RETURNING id, name",
+		"SELECT DISTINCT x.v AS vv, COUNT(*) c, UPPER(name) FROM t x WHERE (id, v) >= (1, 'a') AND name LIKE 'a%' ESCAPE '!' OR id IN (1, 2) GROUP BY v ORDER BY COUNT(*) DESC NULLS LAST LIMIT 1 OFFSET 2 INTO OUTFILE 'f'",
+		"SELECT id FROM t WHERE v IS NOT NULL AND id NOT BETWEEN 1 AND 2 GROUP BY ROLLUP (id)",
+		"UPDATE t SET name = 'x', v = 'y' FROM t3 WHERE t.id = t3.id RETURNING *",
+		"DELETE FROM t WHERE id = 1 AND (v = 'a' OR v IS NULL) RETURNING id",
+		"ALTER TABLE t MODIFY name VARCHAR(20) NOT NULL",
+		"COMMENT ON COLUMN t.name IS 'x'",
+	}
+	// 末尾を切り詰めた文と、トークンを1つ抜いた文はエラーになってもpanicしない
+	queries := []string{"SELECT DISTINCT x.v AS vv", "SELECT * t INTO OUTFILE 'f'", "SELECT * t WHERE (id, v) >= (1, 'a')", "SELECT INTO OUTFILE 'f'"}
+	for _, stmt := range statements {
+		tokens, err := tokenize(stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := make([]string, len(tokens))
+		for i, token := range tokens {
+			parts[i] = displayToken(token)
+		}
+		for n := 1; n < len(parts); n++ {
+			queries = append(queries, strings.Join(parts[:n], " "))
+			queries = append(queries, strings.Join(slices.Delete(slices.Clone(parts), n-1, n), " "))
+		}
+	}
+
+	for _, query := range queries {
+		_, p := newTestDB(t)
+		mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10), v VARCHAR(5))")
+		mustExec(t, p, "CREATE TABLE t3 (id INTEGER)")
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%s: panic: %v", query, r)
+				}
+			}()
+			p.Exec(query)
+		}()
+	}
+
+	// 切り詰めた文はエラーを返す
+	for _, query := range []string{"SELECT * FROM", "UPDATE t SET x =", "INSERT INTO t VALUES (1,", "DELETE FROM t WHERE", "CREATE TABLE x (", "CREATE TABLE x ()", "CREATE TABLE x (a INTEGER) extra", "SELECT INTO OUTFILE 'f'"} {
+		_, p := newTestDB(t)
+		mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10), v VARCHAR(5))")
+		if _, err := p.Exec(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+func TestDeleteWhereShapes(t *testing.T) {
+	tests := []struct {
+		where string
+		left  []string
+	}{
+		{"active = TRUE", []string{"2", "4", "5"}},
+		{"active = FALSE", []string{"1", "3", "5"}},
+		{"name IS NULL", []string{"1", "3", "4", "5"}},
+		{"active IS NOT NULL", []string{"5"}},
+		{"id IN (1, 3)", []string{"2", "4", "5"}},
+		{"id NOT IN (1, 3)", []string{"1", "3"}},
+		{"id BETWEEN 2 AND 4", []string{"1", "5"}},
+		{"name LIKE 'd%'", []string{"1", "2", "3", "5"}},
+		{"id > 1 AND (active = TRUE OR name IS NULL)", []string{"1", "4", "5"}},
+		{"LENGTH(name) = 1 AND id * 2 >= 6", []string{"1", "2"}},
+		{"(id, name) = (3, 'c')", []string{"1", "2", "4", "5"}},
+		{"id = 99", []string{"1", "2", "3", "4", "5"}},
+	}
+	for _, tt := range tests {
+		_, p := newTestDB(t)
+		mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, active BOOLEAN, name VARCHAR(10))")
+		mustExec(t, p, "INSERT INTO t VALUES (1, TRUE, 'a'), (2, FALSE, NULL), (3, TRUE, 'c'), (4, FALSE, 'd'), (5, NULL, 'e')")
+		mustExec(t, p, "DELETE FROM t WHERE "+tt.where)
+		assertValues(t, mustExec(t, p, "SELECT id FROM t ORDER BY id"), "id", tt.left...)
+	}
+
+	// 不正なWHERE条件は何も削除せずにエラーになる
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, active BOOLEAN)")
+	mustExec(t, p, "INSERT INTO t VALUES (1, TRUE)")
+	mustFail(t, p, "DELETE FROM t WHERE", "incomplete WHERE condition")
+	mustFail(t, p, "DELETE FROM t WHERE id =", "incomplete WHERE condition")
+	mustFail(t, p, "DELETE FROM t WHERE id = 1 AND", "incomplete WHERE condition")
+	mustFail(t, p, "DELETE FROM t WHERE (id = 1", "missing ')' in WHERE clause")
+	mustFail(t, p, "DELETE FROM t WHERE id IS 1", "expected NULL after IS")
+	mustFail(t, p, "DELETE FROM t WHERE id == 1", "unknown operator '==' in WHERE clause")
+	mustFail(t, p, "DELETE FROM t WHERE id = 1 OR OR id = 2", "unknown operator 'id' in WHERE clause")
+	mustFail(t, p, "DELETE FROM t WHRE id = 1", "unexpected token in DELETE: WHRE")
+	mustFail(t, p, "DELETE FROM t WHERE missing = 1", "column 'missing' does not exist")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t"), "id", "1")
+}
+
+func TestQueryDuration(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10))")
+
+	result := mustExec(t, p, "INSERT INTO t VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+	if result.Duration <= 0 {
+		t.Errorf("INSERT Duration = %v, want > 0", result.Duration)
+	}
+	results, err := p.ParseAll("SELECT * FROM t; SELECT * FROM t WHERE id = 9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Duration <= 0 {
+			t.Errorf("SELECT Duration = %v, want > 0", result.Duration)
+		}
+	}
+
+	// Timingなしでは従来どおり行数だけを表示する
+	rows := results[0]
+	if got := rows.Summary(); got != "3 row(s) returned" {
+		t.Errorf("Summary() = %q", got)
+	}
+	rows.Options = &DisplayOptions{Null: "NULL", Separator: "|", Timing: true}
+	rows.Duration = 1200 * time.Microsecond
+	if got := rows.Summary(); got != "(3 rows, 1.2ms)" {
+		t.Errorf("Summary() with timing = %q", got)
+	}
+	empty := results[1]
+	empty.Options = rows.Options
+	empty.Duration = 1500 * time.Millisecond
+	if got := empty.Summary(); got != "(0 rows, 1.50s)" {
+		t.Errorf("Summary() for no rows = %q", got)
+	}
+
+	for d, want := range map[time.Duration]string{
+		45 * time.Microsecond:   "0.045ms",
+		1234 * time.Microsecond: "1.2ms",
+		250 * time.Millisecond:  "250.0ms",
+		2 * time.Second:         "2.00s",
+	} {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestMalformedDMLStatements(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10), v VARCHAR(5))")
+	mustExec(t, p, "CREATE TABLE t3 (id INTEGER)")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 'a', 'x')")
+
+	tests := []struct{ query, want string }{
+		// INSERT
+		{"INSERT", "invalid INSERT syntax"},
+		{"INSERT INTO", "invalid INSERT syntax at end of statement"},
+		{"INSERT INTO t", "invalid INSERT syntax at end of statement"},
+		{"INSERT INTO VALUES (1, 'b', 'y')", "missing table name after INTO"},
+		{"INSERT OR IGNORE INTO", "invalid INSERT syntax at end of statement"},
+		{"INSERT OR IGNORE INTO t VALUES (2,, 'y')", "expected a value in VALUES clause near ',' (position 35)"},
+		{"INSERT INTO t VALUES (2 'b' 'y')", "expected ',' or ')' in VALUES clause near 'b'"},
+		{"INSERT INTO t VALUES (2, 'b', 'y',)", "expected a value in VALUES clause near ')'"},
+		{"INSERT INTO t VALUES (2, 'b', 'y'), ", "expected '(' in VALUES clause at end of statement"},
+		{"INSERT INTO t VALUES", "expected '(' in VALUES clause at end of statement"},
+		{"INSERT INTO t (id name) VALUES (2, 'b')", "expected ',' or ')' in INSERT column list near 'name'"},
+		{"INSERT INTO t (id,) VALUES (2)", "expected a value in INSERT column list"},
+		{"INSERT INTO t (id, name", "missing VALUES clause"},
+		{"INSERT INTO t VALUES (2, 'b', 'y') RETURNING", "RETURNING requires at least one column"},
+		{"INSERT INTO t VALUES (2, 'b', 'y') RETURNING id,", "RETURNING requires at least one column"},
+		{"INSERT INTO t VALUES (2, 'b', 'y') RETURNING , id", "expected column name in RETURNING clause"},
+		// UPDATE
+		{"UPDATE", "invalid UPDATE syntax"},
+		{"UPDATE t SET", "invalid UPDATE syntax"},
+		{"UPDATE t SET name = WHERE id = 1", "missing value for 'name' in SET clause near 'WHERE'"},
+		{"UPDATE t SET name = , v = 'z'", "missing value for 'name' in SET clause"},
+		{"UPDATE t SET name = 'b',", "invalid SET syntax at end of statement"},
+		{"UPDATE t SET name 'b' WHERE id = 1", "expected '='"},
+		{"UPDATE t SET name = 'b' FROM WHERE t.id = 1", "missing table name after FROM near 'WHERE'"},
+		{"UPDATE t SET name = 'b' WHERE", "WHERE"},
+		{"UPDATE t SET name = 'b' RETURNING", "RETURNING requires at least one column"},
+		// DELETE
+		{"DELETE", "invalid DELETE syntax"},
+		{"DELETE FROM", "invalid DELETE syntax at end of statement"},
+		{"DELETE FROM WHERE id = 1", "missing table name after FROM near 'WHERE'"},
+		{"DELETE FROM t WHERE", "WHERE"},
+		{"DELETE FROM t WHERE id =", "WHERE"},
+		{"DELETE FROM t RETURNING", "RETURNING requires at least one column"},
+		{"DELETE FROM t RETURNING id name", "unexpected 'name' in RETURNING clause"},
+	}
+	for _, tt := range tests {
+		mustFail(t, p, tt.query, tt.want)
+	}
+	// 失敗した文はデータを変更しない
+	assertValues(t, mustExec(t, p, "SELECT name FROM t"), "name", "a")
+
+	// 末尾の ; はRETURNINGの後でも文の終わり
+	assertValues(t, mustExec(t, p, "INSERT OR IGNORE INTO t VALUES (2, 'b', 'y') RETURNING id;"), "id", "2")
+	assertValues(t, mustExec(t, p, "UPDATE t SET v = 'z' WHERE id = 2 RETURNING v;"), "v", "z")
+	assertValues(t, mustExec(t, p, "DELETE FROM t WHERE id = 2 RETURNING name;"), "name", "b")
+}
+
+func TestValueKeysFollowColumnType(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE k (id INTEGER PRIMARY KEY, s VARCHAR(20) UNIQUE, d DATETIME UNIQUE)")
+	mustExec(t, p, "CREATE INDEX k_s ON k (s)")
+
+	// VARCHARは日時として解釈しない
+	mustExec(t, p, "INSERT INTO k VALUES (1, '2024-01-01', '2024-01-01')")
+	mustExec(t, p, "INSERT INTO k VALUES (2, '2024-01-01 00:00:00', '2024-01-02')")
+	assertValues(t, mustExec(t, p, "SELECT id FROM k WHERE s = '2024-01-01 00:00:00'"), "id", "2")
+	assertValues(t, mustExec(t, p, "SELECT id FROM k WHERE s = '2024-01-01'"), "id", "1")
+
+	// DATETIMEは正規化した日時で比較する
+	mustFail(t, p, "INSERT INTO k VALUES (3, 'x', '2024-01-01 00:00:00')", "duplicate")
+
+	// 2^53を超える整数も正確に区別する
+	mustExec(t, p, "INSERT INTO k (id) VALUES (9007199254740992)")
+	mustExec(t, p, "INSERT INTO k (id) VALUES (9007199254740993)")
+	mustFail(t, p, "INSERT INTO k (id) VALUES (9007199254740993)", "duplicate")
+	assertValues(t, mustExec(t, p, "SELECT id FROM k WHERE id = 9007199254740993"), "id", "9007199254740993")
+
+	// 複数カラムの主キー
+	mustExec(t, p, "CREATE TABLE c (a INTEGER, b VARCHAR(20), PRIMARY KEY (a, b))")
+	mustExec(t, p, "INSERT INTO c VALUES (9007199254740992, '2024-01-01')")
+	mustExec(t, p, "INSERT INTO c VALUES (9007199254740993, '2024-01-01')")
+	mustExec(t, p, "INSERT INTO c VALUES (9007199254740992, '2024-01-01 00:00:00')")
+	mustFail(t, p, "INSERT INTO c VALUES (9007199254740993, '2024-01-01')", "duplicate")
+
+	if got, want := valueKey(3.0, TypeFloat), valueKey(3, TypeInteger); got != want {
+		t.Errorf("valueKey(3.0) = %q, want %q", got, want)
+	}
+}
+
+func TestAlterTableRejectsLeftoverTokens(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, a INTEGER)")
+
+	mustFail(t, p, "ALTER TABLE t ADD fk INTEGER REFERENCES t(id)", "REFERENCES is not supported in a column definition")
+	mustFail(t, p, "ALTER TABLE t ADD COLUMN b INTEGER garbage here", "unexpected token in definition of column b: garbage")
+	mustFail(t, p, "ALTER TABLE t ADD b INTEGER NOT", "expected NULL after NOT")
+	mustFail(t, p, "ALTER TABLE t ADD b INTEGER, c INTEGER", "unexpected token in ALTER TABLE: ,")
+	mustFail(t, p, "ALTER TABLE t DROP COLUMN a extra", "unexpected token in ALTER TABLE: extra")
+	mustFail(t, p, "ALTER TABLE t MODIFY a VARCHAR(10) junk", "unexpected token in definition of column a: junk")
+	mustFail(t, p, "ALTER TABLE t MODIFY a INTEGER) x", "unexpected token in ALTER TABLE: )")
+	if cols := db.Tables["t"].Columns; len(cols) != 2 || cols[1].Type != TypeInteger || len(db.Tables["t"].ForeignKeys) != 0 {
+		t.Fatalf("failed ALTER TABLE changed the table: %+v", db.Tables["t"])
+	}
+
+	// CREATE TABLEのカラム定義も同じく不明な語を拒否する
+	mustFail(t, p, "CREATE TABLE u (id INTEGER PRIMARY, name VARCHAR(10))", "expected KEY after PRIMARY")
+	mustFail(t, p, "CREATE TABLE u (id INTEGER SIGNED)", "unexpected token in definition of column id: SIGNED")
+
+	mustExec(t, p, "ALTER TABLE t ADD COLUMN b INTEGER NULL DEFAULT 0;")
+	mustExec(t, p, "ALTER TABLE t MODIFY b INTEGER NOT NULL;")
+	mustExec(t, p, "ALTER TABLE t DROP COLUMN a;")
+	if got := db.Tables["t"].Columns; len(got) != 2 || got[1].Name != "b" || !got[1].NotNull {
+		t.Errorf("columns = %+v", got)
+	}
+}
+
+func TestDateComparisonFollowsColumnType(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE e (id INTEGER PRIMARY KEY, s VARCHAR(20), d DATE, dt DATETIME)")
+	mustExec(t, p, "INSERT INTO e VALUES (1, '2024-01-01', '2024-01-01', '2024-01-01 00:00:00')")
+	mustExec(t, p, "INSERT INTO e VALUES (2, '2024-01-01 00:00:00', '2024-01-02', '2024-01-01 12:00:00')")
+
+	// VARCHARは文字列として比較する
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE s = '2024-01-01 00:00:00'"), "id", "2")
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE s < '2024-01-01 00:00:00'"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE s IN ('2024-01-01T00:00:00')"), "id")
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE s BETWEEN 'a' AND 'z'"), "id")
+
+	// DATE/DATETIMEは日時として比較する（DATEとDATETIMEの混在も）
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE d = '2024-01-01 00:00:00'"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE dt = '2024-01-01T12:00:00'"), "id", "2")
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE d <= '2024-01-01 12:00:00' ORDER BY id"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE dt IN ('2024-01-01') ORDER BY id"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE e.d = e.dt ORDER BY id"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM e WHERE (d, id) >= ('2024-01-01 00:00:00', 1) ORDER BY id"), "id", "1", "2")
+	mustFail(t, p, "SELECT id FROM e WHERE d BETWEEN 'a' AND 'z'", "cannot compare DATE column 'd'")
+
+	if compareValues("2024-01-01", "2024-01-01 00:00:00") >= 0 {
+		t.Error("compareValues should compare untyped strings as text")
+	}
+	if compareAs("2024-01-01", "2024-01-01 00:00:00", TypeDate) != 0 {
+		t.Error("compareAs should compare DATE values as timestamps")
+	}
+}
+
+func TestSumIntegersExactly(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE n (id INTEGER PRIMARY KEY, v INTEGER, f FLOAT)")
+	mustExec(t, p, "INSERT INTO n VALUES (1, 9007199254740993, 0.5), (2, 2, 0.25), (3, NULL, NULL)")
+
+	result := mustExec(t, p, "SELECT SUM(v) AS s, SUM(f) AS sf FROM n")
+	if got := result.Rows[0]["s"]; got != 9007199254740995 {
+		t.Errorf("SUM(v) = %v (%T), want 9007199254740995", got, got)
+	}
+	if got := result.Rows[0]["sf"]; got != 0.75 {
+		t.Errorf("SUM(f) = %v", got)
+	}
+
+	assertValues(t, mustExec(t, p, "SELECT AVG(v) AS a FROM n WHERE id > 1"), "a", "2")
+
+	mustExec(t, p, "INSERT INTO n (id, v) VALUES (4, 9223372036854775807)")
+	mustFail(t, p, "SELECT SUM(v) FROM n", "SUM: result out of range")
+}
+
+func TestNullIf(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE s (id INTEGER PRIMARY KEY, amount INTEGER, cnt INTEGER, status VARCHAR(10))")
+	mustExec(t, p, "INSERT INTO s VALUES (1, 10, 2, 'ok'), (2, 7, 0, 'unknown'), (3, NULL, 4, NULL)")
+
+	// 一致すればNULL、一致しなければ最初の引数。NULLは算術式に伝わり0除算を避ける
+	result := mustExec(t, p, "SELECT amount / NULLIF(cnt, 0) AS avg, NULLIF(status, 'unknown') AS st FROM s ORDER BY id")
+	assertValues(t, result, "avg", "5", "NULL", "NULL")
+	assertValues(t, result, "st", "ok", "NULL", "NULL")
+
+	assertValues(t, mustExec(t, p, "SELECT NULLIF(1, 1) AS a"), "a", "NULL")
+	assertValues(t, mustExec(t, p, "SELECT NULLIF(1, 2) AS a"), "a", "1")
+	assertValues(t, mustExec(t, p, "SELECT NULLIF(2, 2.0) AS a"), "a", "NULL")
+	assertValues(t, mustExec(t, p, "SELECT NULLIF('x', NULL) AS a"), "a", "x")
+	assertValues(t, mustExec(t, p, "SELECT id FROM s WHERE NULLIF(status, 'ok') = 'unknown'"), "id", "2")
+	mustFail(t, p, "SELECT NULLIF(1) AS a", "NULLIF")
+}
+
+func TestIntervalArithmetic(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE ev (id INTEGER PRIMARY KEY, d DATE, at DATETIME, n INTEGER)")
+	mustExec(t, p, "INSERT INTO ev VALUES (1, '2024-01-28', '2024-01-31 22:30:00', 5)")
+	mustExec(t, p, "INSERT INTO ev VALUES (2, '2024-02-27', '2024-03-01 01:00:00', 6)")
+
+	// 日単位はDATEのまま、時間単位はDATETIMEになる（月・うるう日をまたぐ）
+	result := mustExec(t, p, "SELECT d + INTERVAL '7 days' AS week, at + INTERVAL '3 hours' AS later, at - INTERVAL '90 minutes' AS earlier, d - INTERVAL '1 hour' AS dh FROM ev ORDER BY id")
+	assertValues(t, result, "week", "2024-02-04", "2024-03-05")
+	assertValues(t, result, "later", "2024-02-01 01:30:00", "2024-03-01 04:00:00")
+	assertValues(t, result, "earlier", "2024-01-31 21:00:00", "2024-02-29 23:30:00")
+	assertValues(t, result, "dh", "2024-01-27 23:00:00", "2024-02-26 23:00:00")
+
+	assertValues(t, mustExec(t, p, "SELECT at + INTERVAL '-1 day' AS a FROM ev WHERE id = 2"), "a", "2024-02-29 01:00:00")
+	assertValues(t, mustExec(t, p, "SELECT INTERVAL '1 day' + '2024-12-31' AS a"), "a", "2025-01-01")
+	if got := mustExec(t, p, "SELECT at + INTERVAL '1 hour' FROM ev WHERE id = 1").Columns; got[0] != "at + INTERVAL '1 hour'" {
+		t.Errorf("column name = %q", got[0])
+	}
+
+	// WHEREでは日時として比較する
+	assertValues(t, mustExec(t, p, "SELECT id FROM ev WHERE at + INTERVAL '2 hours' > '2024-02-01'"), "id", "1", "2")
+	assertValues(t, mustExec(t, p, "SELECT id FROM ev WHERE d + INTERVAL '3 days' = '2024-03-01 00:00:00'"), "id", "2")
+	mustExec(t, p, "DELETE FROM ev WHERE at - INTERVAL '1 day' < '2024-02-01'")
+	assertValues(t, mustExec(t, p, "SELECT id FROM ev"), "id", "2")
+
+	mustFail(t, p, "SELECT n + INTERVAL '1 day' FROM ev", "cannot add INTERVAL to non-date value 6")
+	mustFail(t, p, "SELECT at * INTERVAL '1 day' FROM ev", "cannot apply '*' to INTERVAL")
+	mustFail(t, p, "SELECT INTERVAL '1 day' - at FROM ev", "cannot apply '-' to INTERVAL")
+	mustFail(t, p, "SELECT at + INTERVAL '1 week' FROM ev", "invalid INTERVAL '1 week'")
+	mustFail(t, p, "SELECT at + INTERVAL 'x days' FROM ev", "invalid INTERVAL 'x days'")
+}
+
+func TestShowTablesAndColumns(t *testing.T) {
+	_, p := newTestDB(t)
+	assertValues(t, mustExec(t, p, "SHOW TABLES"), "table")
+
+	mustExec(t, p, "CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(20) NOT NULL, score FLOAT DEFAULT 0, email VARCHAR(50) UNIQUE)")
+	mustExec(t, p, "CREATE TABLE audit (at DATETIME)")
+	mustExec(t, p, "COMMENT ON TABLE users IS 'registered users'")
+
+	tables := mustExec(t, p, "SHOW TABLES;")
+	assertValues(t, tables, "table", "audit", "users")
+	assertValues(t, tables, "comment", "NULL", "registered users")
+
+	columns := mustExec(t, p, "show columns from users")
+	assertValues(t, columns, "name", "id", "name", "score", "email")
+	assertValues(t, columns, "type", "INTEGER", "VARCHAR", "FLOAT", "VARCHAR")
+	assertValues(t, columns, "size", "NULL", "20", "NULL", "50")
+	assertValues(t, columns, "not_null", "false", "true", "false", "false")
+	assertValues(t, columns, "primary", "true", "false", "false", "false")
+	assertValues(t, columns, "unique", "false", "false", "false", "true")
+	assertValues(t, columns, "default", "NULL", "NULL", "0", "NULL")
+
+	mustFail(t, p, "SHOW TABLES junk", "unexpected token in SHOW TABLES: junk")
+	mustFail(t, p, "SHOW COLUMNS FROM users extra", "unexpected token in SHOW COLUMNS: extra")
+	mustFail(t, p, "SHOW CREATE TABLE users extra", "unexpected token in SHOW CREATE TABLE: extra")
+	mustFail(t, p, "SHOW COLUMNS users", "invalid SHOW COLUMNS syntax")
+	mustFail(t, p, "SHOW COLUMNS FROM missing", "table 'missing' does not exist")
+	mustFail(t, p, "SHOW INDEXES", "unknown SHOW target: INDEXES")
+}
+
+func TestCommentRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	p := NewSQLParser(NewDatabaseAt("test", dir))
+	mustExec(t, p, "CREATE TABLE users (id INTEGER PRIMARY KEY, email VARCHAR(50), name VARCHAR(20))")
+	mustExec(t, p, "COMMENT ON TABLE users IS 'application users'")
+	mustExec(t, p, "COMMENT ON COLUMN users.email IS 'login email; it''s unique'")
+	mustExec(t, p, "COMMENT ON COLUMN users.name IS 'display name';")
+	mustExec(t, p, "COMMENT ON COLUMN users.name IS NULL")
+
+	mustFail(t, p, "COMMENT ON COLUMN users.name IS notastring", "COMMENT requires a string literal or NULL")
+	mustFail(t, p, "COMMENT ON TABLE users IS 'x' extra", "unexpected token in COMMENT: extra")
+	mustFail(t, p, "COMMENT ON COLUMN email IS 'x'", "column must be qualified as table.column")
+
+	// 保存・再読み込み後もコメントが残る
+	loaded, err := LoadDatabaseAt("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := NewSQLParser(loaded)
+	assertValues(t, mustExec(t, lp, "SHOW TABLES"), "comment", "application users")
+	assertValues(t, mustExec(t, lp, "SHOW COLUMNS FROM users"), "comment", "NULL", "login email; it's unique", "NULL")
+
+	// 空文字列で削除したコメントも再読み込み後に残らない
+	mustExec(t, lp, "COMMENT ON TABLE users IS ''")
+	reloaded, err := LoadDatabaseAt("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, mustExec(t, NewSQLParser(reloaded), "SHOW TABLES"), "comment", "NULL")
+}
+
+func TestCheckDatabaseReportsViolations(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE users (id INTEGER PRIMARY KEY, email VARCHAR(20) UNIQUE, name VARCHAR(10) NOT NULL, age INTEGER CHECK (age >= 0))")
+	mustExec(t, p, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, FOREIGN KEY (user_id) REFERENCES users(id))")
+	mustExec(t, p, "CREATE TABLE pairs (a INTEGER, b INTEGER, PRIMARY KEY (a, b))")
+	mustExec(t, p, "INSERT INTO users VALUES (1, 'a@x', 'Alice', 30)")
+	mustExec(t, p, "INSERT INTO orders VALUES (1, 1)")
+	mustExec(t, p, "INSERT INTO pairs VALUES (1, 2)")
+	if result := mustExec(t, p, "CHECK DATABASE"); result.Message != "No integrity violations found" {
+		t.Fatalf("clean database reported %q", result.Message)
+	}
+
+	// ファイルを直接編集したのと同じく、制約を通さずに行を追加する
+	db.Tables["users"].Rows = append(db.Tables["users"].Rows,
+		Row{"id": 1, "email": "a@x", "name": nil, "age": -5},
+		Row{"id": nil, "email": "b@x", "name": "Bob", "age": "old"})
+	db.Tables["orders"].Rows = append(db.Tables["orders"].Rows, Row{"id": 2, "user_id": 99})
+	db.Tables["pairs"].Rows = append(db.Tables["pairs"].Rows, Row{"a": 1, "b": 2})
+
+	result := mustExec(t, p, "CHECK DATABASE;")
+	got := []string{}
+	for _, row := range result.Rows {
+		got = append(got, fmt.Sprintf("%v/%v/%v: %v", row["table"], row["row"], row["column"], row["problem"]))
+	}
+	want := []string{
+		"orders/2/user_id: foreign key value 99 not found in users(id)",
+		"pairs/2/a, b: duplicate primary key value (1, 2) (first seen in row 1)",
+		"users/2/id: duplicate primary key value 1 (first seen in row 1)",
+		"users/2/email: duplicate unique value a@x (first seen in row 1)",
+		"users/2/name: NOT NULL constraint violated",
+		"users/2/age: CHECK constraint violated: age >= 0",
+		"users/3/id: primary key cannot be null",
+		"users/3/age: invalid value old",
+	}
+	for _, w := range want {
+		if !slices.ContainsFunc(got, func(g string) bool { return strings.HasPrefix(g, w) }) {
+			t.Errorf("missing violation %q in:\n%s", w, strings.Join(got, "\n"))
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d violations, want %d:\n%s", len(got), len(want), strings.Join(got, "\n"))
+	}
+	if errs := db.VerifyIntegrity(); len(errs) != len(got) {
+		t.Errorf("VerifyIntegrity returned %d errors, CHECK DATABASE %d rows", len(errs), len(got))
+	}
+
+	mustFail(t, p, "CHECK DATABASE extra", "unexpected token in CHECK DATABASE: extra")
+	mustFail(t, p, "CHECK TABLE users", "invalid CHECK syntax")
+}
+
+func TestSetRejectsLeftoverTokens(t *testing.T) {
+	db, p := newTestDB(t)
+	mustFail(t, p, "SET strict = on trailing", "unexpected token in SET: trailing")
+	mustFail(t, p, "SET max_result_rows = 5 10", "unexpected token in SET: 10")
+	mustFail(t, p, "SET max_result_rows_action = truncate now", "unexpected token in SET: now")
+	// 拒否した文の値は反映しない
+	if db.strict || db.maxResultRows != 0 || db.truncateResults {
+		t.Fatalf("rejected SET changed options: strict=%v max=%d truncate=%v", db.strict, db.maxResultRows, db.truncateResults)
+	}
+
+	mustExec(t, p, "SET strict = on;")
+	if result := mustExec(t, p, "SET max_result_rows = 5"); result.Message != "max_result_rows = 5" {
+		t.Fatalf("message = %q", result.Message)
+	}
+	if !db.strict || db.maxResultRows != 5 {
+		t.Fatalf("SET not applied: strict=%v max=%d", db.strict, db.maxResultRows)
+	}
+}
+
+func TestCreateIndexRejectsLeftoverTokens(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (a INTEGER, b INTEGER)")
+	mustFail(t, p, "CREATE INDEX i1 ON t (a) junk", "unexpected token in CREATE INDEX: junk")
+	mustFail(t, p, "CREATE INDEX i1 ON t (a, b)", "invalid CREATE INDEX syntax")
+	if n := len(db.Tables["t"].Indexes); n != 0 {
+		t.Fatalf("rejected CREATE INDEX left %d indexes", n)
+	}
+	mustExec(t, p, "CREATE INDEX i1 ON t (a);")
+	if n := len(db.Tables["t"].Indexes); n != 1 {
+		t.Fatalf("indexes = %d, want 1", n)
+	}
+}
+
+func TestMaxResultRows(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, v INTEGER)")
+	for i := 1; i <= 5; i++ {
+		mustExec(t, p, "INSERT INTO t VALUES (?, ?)", i, 1)
+	}
+	// 6行目は式の評価でエラーになる。走査が途中で止まれば評価されない
+	mustExec(t, p, "INSERT INTO t VALUES (6, 0)")
+	mustExec(t, p, "SET max_result_rows = 3")
+
+	mustFail(t, p, "SELECT id, 10 / v AS q FROM t", "result exceeds max_result_rows (3)")
+	mustFail(t, p, "SELECT id FROM t ORDER BY id DESC", "result exceeds max_result_rows (3)")
+	mustFail(t, p, "SELECT DISTINCT id FROM t", "result exceeds max_result_rows (3)")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE id > 3"), "id", "4", "5", "6")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t LIMIT 3 OFFSET 2"), "id", "3", "4", "5")
+	assertValues(t, mustExec(t, p, "SELECT COUNT(*) FROM t"), "COUNT(*)", "6")
+
+	mustExec(t, p, "SET max_result_rows_action = truncate")
+	result := mustExec(t, p, "SELECT id, 10 / v AS q FROM t")
+	assertValues(t, result, "id", "1", "2", "3")
+	if result.Warning != "result truncated to 3 row(s) (max_result_rows)" {
+		t.Fatalf("warning = %q", result.Warning)
+	}
+	assertValues(t, mustExec(t, p, "SELECT id FROM t OFFSET 1"), "id", "2", "3", "4")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t ORDER BY id DESC"), "id", "6", "5", "4")
+	if result := mustExec(t, p, "SELECT id FROM t WHERE id <= 3"); result.Warning != "" {
+		t.Fatalf("unexpected warning %q", result.Warning)
+	}
+
+	mustExec(t, p, "SET max_result_rows = 0")
+	mustFail(t, p, "SELECT id, 10 / v AS q FROM t", "division by zero")
+}
+
+func TestSelectIntoOutfile(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE users (id INTEGER, name VARCHAR(20), active BOOLEAN)")
+	mustExec(t, p, "INSERT INTO users VALUES (1, 'Alice', TRUE)")
+	mustExec(t, p, "INSERT INTO users VALUES (2, 'Smith, \"Bob\"', TRUE)")
+	mustExec(t, p, "INSERT INTO users VALUES (3, NULL, TRUE)")
+	mustExec(t, p, "INSERT INTO users VALUES (4, 'Dave', FALSE)")
+
+	path := filepath.Join(t.TempDir(), "active.csv")
+	result := mustExec(t, p, "SELECT id, name FROM users WHERE active = TRUE ORDER BY id INTO OUTFILE ?", path)
+	if want := fmt.Sprintf("3 row(s) written to '%s'", path); result.Message != want {
+		t.Fatalf("message = %q, want %q", result.Message, want)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1行目はヘッダー、カンマ・引用符はエスケープ、NULLは空フィールド
+	want := "id,name\n1,Alice\n2,\"Smith, \"\"Bob\"\"\"\n3,\n"
+	if string(data) != want {
+		t.Fatalf("file contents = %q, want %q", data, want)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing", "x.csv")
+	mustFail(t, p, "SELECT * FROM users INTO OUTFILE '"+missing+"'", "no such file or directory")
+}
+
+func TestUnterminatedQuotes(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, name VARCHAR(20))")
+
+	mustFail(t, p, "INSERT INTO t VALUES (1, 'abc)", "unterminated string literal")
+	mustFail(t, p, "SELECT * FROM t WHERE name = 'it''s", "unterminated string literal")
+	mustFail(t, p, "SELECT \"name FROM t", "unterminated quoted identifier")
+	mustFail(t, p, "SELECT `name FROM t", "unterminated quoted identifier")
+	// 別の種類の引用符では閉じない
+	mustFail(t, p, "SELECT * FROM t WHERE name = 'a\"", "unterminated string literal")
+	mustFail(t, p, "SELECT `name\" FROM t", "unterminated quoted identifier")
+	assertValues(t, mustExec(t, p, "SELECT COUNT(*) FROM t"), "COUNT(*)", "0")
+
+	// スクリプトの分割でも、閉じられていない引用符は開始行とともに報告する
+	for script, want := range map[string]string{
+		"SELECT 1;\nSELECT 'a;\nSELECT 2;": "line 2: unterminated string literal",
+		"SELECT 1;\nSELECT \"a;":           "line 2: unterminated quoted identifier",
+		"SELECT `a;":                       "line 1: unterminated quoted identifier",
+	} {
+		if _, err := splitStatements(script); err == nil || err.Error() != want {
+			t.Errorf("splitStatements(%q) error = %v, want %q", script, err, want)
+		}
+	}
+}
+
+func TestNullOrdering(t *testing.T) {
+	for _, tc := range []struct {
+		a, b       interface{}
+		nullsFirst bool
+		cmp        int
+		ok         bool
+	}{
+		{nil, nil, false, 0, true},
+		{nil, nil, true, 0, true},
+		{nil, 1, false, 1, true},
+		{nil, 1, true, -1, true},
+		{1, nil, false, -1, true},
+		{1, nil, true, 1, true},
+		{1, 2, true, 0, false},
+	} {
+		cmp, ok := compareNulls(tc.a, tc.b, tc.nullsFirst)
+		if cmp != tc.cmp || ok != tc.ok {
+			t.Errorf("compareNulls(%v, %v, %v) = %d, %v; want %d, %v", tc.a, tc.b, tc.nullsFirst, cmp, ok, tc.cmp, tc.ok)
+		}
+	}
+	if compareNullable(nil, 1) != 1 || compareNullable(1, nil) != -1 || compareNullable(1, 2) != -1 {
+		t.Error("compareNullable must sort NULL after every value")
+	}
+
+	// sortRowsはNULLの位置をDESCで反転させず、NULLS FIRST / LAST に従う
+	sorted := func(item OrderByItem) []string {
+		rows := []Row{{"v": 2}, {"v": nil}, {"v": 1}, {"v": nil}, {"v": 3}}
+		sortRows(rows, []OrderByItem{item})
+		values := []string{}
+		for _, row := range rows {
+			values = append(values, fmt.Sprint(row["v"]))
+		}
+		return values
+	}
+	for _, tc := range []struct {
+		item OrderByItem
+		want string
+	}{
+		{OrderByItem{Column: "v"}, "1 2 3 <nil> <nil>"},
+		{OrderByItem{Column: "v", Desc: true}, "<nil> <nil> 3 2 1"},
+		{OrderByItem{Column: "v", Nulls: "FIRST"}, "<nil> <nil> 1 2 3"},
+		{OrderByItem{Column: "v", Desc: true, Nulls: "LAST"}, "3 2 1 <nil> <nil>"},
+	} {
+		if got := strings.Join(sorted(tc.item), " "); got != tc.want {
+			t.Errorf("sortRows(%+v) = %s, want %s", tc.item, got, tc.want)
+		}
+	}
+}
+
+func TestVarcharSize(t *testing.T) {
+	_, p := newTestDB(t)
+	mustFail(t, p, "CREATE TABLE a (s VARCHAR)", "missing size for VARCHAR column s")
+	mustFail(t, p, "CREATE TABLE a (s VARCHAR(0))", "invalid size for VARCHAR column s: 0")
+	mustFail(t, p, "CREATE TABLE a (s VARCHAR(-1))", "invalid size for VARCHAR column s: -1")
+	mustFail(t, p, "CREATE TABLE a (s VARCHAR(x))", "invalid size for VARCHAR column s: x")
+	mustFail(t, p, "CREATE TABLE a (s VARCHAR(3, 2))", "invalid size for VARCHAR column s")
+	mustFail(t, p, "CREATE TABLE a (s VARCHAR(99999999999999999999))", "invalid size for VARCHAR column s")
+
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, s VARCHAR(3))")
+	mustFail(t, p, "ALTER TABLE t ADD COLUMN x VARCHAR(0)", "invalid size for VARCHAR column x: 0")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 'abc')")
+	// サイズは文字数で数える
+	mustExec(t, p, "INSERT INTO t VALUES (2, 'あいう')")
+	mustFail(t, p, "INSERT INTO t VALUES (3, 'abcd')", "column 's': string too long (max 3)")
+	mustFail(t, p, "INSERT INTO t VALUES (3, 'あいうえ')", "column 's': string too long (max 3)")
+	mustFail(t, p, "UPDATE t SET s = 'abcd' WHERE id = 1", "column 's': string too long (max 3)")
+	mustFail(t, p, "ALTER TABLE t MODIFY COLUMN s VARCHAR(2)", "row 1: column 's': string too long (max 2)")
+	assertValues(t, mustExec(t, p, "SELECT s FROM t ORDER BY id"), "s", "abc", "あいう")
+}
+
+func TestDumpRoundTripsLiterals(t *testing.T) {
+	col := Column{Name: "s", Type: TypeVarchar, Size: 50}
+	for value, want := range map[interface{}]string{
+		"O'Brien":      "'O''Brien'",
+		"''":           "''''''",
+		"line1\nline2": "'line1\nline2'",
+		"a;b":          "'a;b'",
+		"":             "''",
+	} {
+		if got := quoteLiteral(value, col); got != want {
+			t.Errorf("quoteLiteral(%q) = %s, want %s", value, got, want)
+		}
+	}
+	if got := quoteLiteral(nil, col); got != "NULL" {
+		t.Errorf("quoteLiteral(nil) = %s, want NULL", got)
+	}
+	if got := quoteLiteral(false, Column{Type: TypeBoolean}); got != "FALSE" {
+		t.Errorf("quoteLiteral(false) = %s, want FALSE", got)
+	}
+
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, s VARCHAR(50), b BOOLEAN)")
+	values := []interface{}{"O'Brien", "it's -- not a comment", "line1\nline2\n", "/* x */ 'q'; DROP", "", nil}
+	for i, value := range values {
+		mustExec(t, p, "INSERT INTO t VALUES (?, ?, ?)", i+1, value, i%2 == 0)
+	}
+	mustExec(t, p, "INSERT INTO t (id) VALUES (?)", len(values)+1)
+
+	var dump strings.Builder
+	if err := db.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	_, restored := newTestDB(t)
+	if _, err := restored.ParseAll(dump.String()); err != nil {
+		t.Fatalf("failed to restore dump: %v\n%s", err, dump.String())
+	}
+	want := mustExec(t, p, "SELECT * FROM t ORDER BY id")
+	got := mustExec(t, restored, "SELECT * FROM t ORDER BY id")
+	if len(got.Rows) != len(want.Rows) {
+		t.Fatalf("restored %d rows, want %d", len(got.Rows), len(want.Rows))
+	}
+	for i := range want.Rows {
+		if !RowEqual(got.Rows[i], want.Rows[i]) {
+			t.Errorf("row %d: restored %v, want %v", i+1, got.Rows[i], want.Rows[i])
+		}
+	}
+	assertValues(t, mustExec(t, restored, "SELECT id FROM t WHERE s IS NULL ORDER BY id"), "id", "6", "7")
+	assertValues(t, mustExec(t, restored, "SELECT id FROM t WHERE s = ''"), "id", "5")
+}
+
+func TestQueryBuilder(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(10), age INTEGER)")
+	for i, name := range []string{"Alice", "Bob", "Carol", "Dave", "Eve"} {
+		if err := db.Table("users").Insert(map[string]interface{}{"id": i + 1, "name": name, "age": 20 + i*5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// SELECT: 複数のWhereはANDで結合し、並べ替えの後にOFFSET / LIMITを適用する
+	result, err := db.Table("users").Where("age", ">=", 25).Where("name", "!=", "Dave").OrderByDesc("age").Offset(1).Limit(2).Select("name", "age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Columns, []string{"name", "age"}) {
+		t.Fatalf("columns = %v", result.Columns)
+	}
+	assertValues(t, result, "name", "Carol", "Bob")
+	result, err = db.Table("users").Where("name", "like", "%e").OrderBy("id").Select()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, result, "id", "1", "4", "5")
+	if _, err := db.Table("users").Select("nmae"); err == nil || !strings.Contains(err.Error(), "column 'nmae' does not exist") {
+		t.Fatalf("expected unknown column error, got %v", err)
+	}
+	if _, err := db.Table("nosuch").Select(); err == nil || !strings.Contains(err.Error(), "table 'nosuch' does not exist") {
+		t.Fatalf("expected unknown table error, got %v", err)
+	}
+
+	// UPDATE: Whereに一致する行だけを変更する
+	n, err := db.Table("users").Where("age", "<", 30).Update(map[string]interface{}{"age": 99})
+	if err != nil || n != 2 {
+		t.Fatalf("Update = %d, %v; want 2 rows", n, err)
+	}
+	assertValues(t, mustExec(t, p, "SELECT id FROM users WHERE age = 99 ORDER BY id"), "id", "1", "2")
+	if _, err := db.Table("users").Where("id", "=", 3).Update(map[string]interface{}{"id": 1}); err == nil || !strings.Contains(err.Error(), "duplicate primary key value: 1") {
+		t.Fatalf("expected duplicate key error, got %v", err)
+	}
+
+	// DELETE: Whereに一致する行だけを削除し、Whereがなければ全行を削除する
+	n, err = db.Table("users").Where("age", "=", 99).Where("name", "=", "Bob").Delete()
+	if err != nil || n != 1 {
+		t.Fatalf("Delete = %d, %v; want 1 row", n, err)
+	}
+	assertValues(t, mustExec(t, p, "SELECT id FROM users ORDER BY id"), "id", "1", "3", "4", "5")
+	if n, err := db.Table("users").Delete(); err != nil || n != 4 {
+		t.Fatalf("Delete all = %d, %v; want 4 rows", n, err)
+	}
+
+	// 不正なLIMIT / OFFSETは実行時にエラーを返し、何も変更しない
+	mustExec(t, p, "INSERT INTO users VALUES (1, 'Alice', 20)")
+	if _, err := db.Table("users").Limit(-1).Select(); err == nil || err.Error() != "invalid LIMIT value: -1" {
+		t.Fatalf("expected LIMIT error, got %v", err)
+	}
+	if _, err := db.Table("users").Offset(-2).Delete(); err == nil || err.Error() != "invalid OFFSET value: -2" {
+		t.Fatalf("expected OFFSET error, got %v", err)
+	}
+	assertValues(t, mustExec(t, p, "SELECT id FROM users"), "id", "1")
+}
+
+func TestWhereParentheses(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, a INTEGER, b INTEGER)")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 1, 1), (2, 1, 2), (3, 2, 2), (4, 2, 1)")
+
+	// 括弧は入れ子にでき、ANDとORの優先順位を変える
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE a = 1 OR b = 2 AND id > 2 ORDER BY id"), "id", "1", "2", "3")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE (a = 1 OR b = 2) AND id > 2 ORDER BY id"), "id", "3")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE ((a = 1 OR b = 2) AND (id > 1 AND (id < 3 OR a = 2))) ORDER BY id"), "id", "2", "3")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE (((a = 2))) ORDER BY id"), "id", "3", "4")
+	mustExec(t, p, "UPDATE t SET b = 9 WHERE (a = 2 AND (b = 1))")
+	mustExec(t, p, "DELETE FROM t WHERE ((id = 1) OR (b = 9))")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t ORDER BY id"), "id", "2", "3")
+
+	// 対応しない括弧
+	mustFail(t, p, "SELECT id FROM t WHERE (a = 1", "missing ')' in WHERE clause at end of statement")
+	mustFail(t, p, "SELECT id FROM t WHERE ((a = 1) OR (b = 2)", "missing ')' in WHERE clause at end of statement")
+	mustFail(t, p, "SELECT id FROM t WHERE (a = 1 ORDER BY id)", "missing ')' in WHERE clause near 'ORDER' (position 31)")
+	mustFail(t, p, "SELECT id FROM t WHERE a = 1)", "unexpected ')' in WHERE clause (position 29)")
+	mustFail(t, p, "SELECT id FROM t WHERE (a = 1))", "unexpected ')' in WHERE clause (position 31)")
+	mustFail(t, p, "SELECT id FROM t WHERE ()", "empty parentheses in WHERE clause near ')' (position 25)")
+	mustFail(t, p, "UPDATE t SET a = 5 WHERE (id = 2", "missing ')' in WHERE clause")
+	mustFail(t, p, "DELETE FROM t WHERE id = 2)", "unexpected ')' in WHERE clause")
+	assertValues(t, mustExec(t, p, "SELECT a FROM t ORDER BY id"), "a", "1", "2")
+}
+
+func TestGetColumnReturnsTableColumn(t *testing.T) {
+	db, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (a INTEGER, b VARCHAR(5), c INTEGER)")
+	table := db.Tables["t"]
+
+	col := table.getColumn("b")
+	if col != &table.Columns[1] {
+		t.Fatalf("getColumn(\"b\") does not point into Table.Columns")
+	}
+	// ポインタを通じた変更は対象のカラムだけに反映される
+	col.Size = 2
+	col.Comment = "short"
+	if table.Columns[1].Size != 2 || table.Columns[1].Comment != "short" {
+		t.Fatalf("change through pointer not persisted: %+v", table.Columns[1])
+	}
+	if table.Columns[0].Comment != "" || table.Columns[2].Comment != "" {
+		t.Fatalf("change leaked into other columns: %+v", table.Columns)
+	}
+	mustFail(t, p, "INSERT INTO t VALUES (1, 'abc', 1)", "string too long (max 2)")
+	if table.getColumn("c") != &table.Columns[2] || table.getColumn("x") != nil {
+		t.Fatal("getColumn returned the wrong column")
+	}
+
+	mustExec(t, p, "COMMENT ON COLUMN t.c IS 'count'")
+	assertValues(t, mustExec(t, p, "SHOW COLUMNS FROM t"), "comment", "NULL", "short", "count")
+	mustFail(t, p, "COMMENT ON COLUMN t.x IS 'y'", "column 'x' does not exist")
+}
+
+func TestLikePatterns(t *testing.T) {
+	for _, tc := range []struct {
+		str, pattern string
+		escape       rune
+		want         bool
+	}{
+		{"Alice", "A%", 0, true},
+		{"Alice", "a%", 0, false},
+		{"Johnson", "%son", 0, true},
+		{"Bob", "%ob%", 0, true},
+		{"Bob", "_ob", 0, true},
+		{"Boob", "_ob", 0, false},
+		{"", "%", 0, true},
+		{"", "_", 0, false},
+		{"あいう", "_い_", 0, true},
+		{"a\nb", "a%b", 0, true},
+		{"a.c", "a.c", 0, true},
+		{"abc", "a.c", 0, false},
+		{"100%", "100!%", '!', true},
+		{"1000", "100!%", '!', false},
+		{"a_b", "a!_b", '!', true},
+		{"axb", "a!_b", '!', false},
+		{"a!b", "a!!b", '!', true},
+		{"a%", "a\\%", '\\', true},
+		{"ab!", "ab!", '!', true}, // 末尾のエスケープ文字はリテラル
+	} {
+		if got := matchLike(tc.str, tc.pattern, tc.escape); got != tc.want {
+			t.Errorf("matchLike(%q, %q, %q) = %v, want %v", tc.str, tc.pattern, tc.escape, got, tc.want)
+		}
+	}
+
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE d (id INTEGER, label VARCHAR(20))")
+	mustExec(t, p, "INSERT INTO d VALUES (1, '100% off'), (2, '1000 yen'), (3, 'a_b'), (4, 'axb'), (5, NULL)")
+	assertValues(t, mustExec(t, p, "SELECT id FROM d WHERE label LIKE '100%' ORDER BY id"), "id", "1", "2")
+	assertValues(t, mustExec(t, p, "SELECT id FROM d WHERE label LIKE '100\\%%' ESCAPE '\\'"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM d WHERE label LIKE 'a_b' ORDER BY id"), "id", "3", "4")
+	assertValues(t, mustExec(t, p, "SELECT id FROM d WHERE label LIKE 'a#_b' ESCAPE '#'"), "id", "3")
+	// NULLはどのパターンにも一致しない
+	assertValues(t, mustExec(t, p, "SELECT id FROM d WHERE label LIKE '%'"), "id", "1", "2", "3", "4")
+}
+
+func TestDropColumnRemovesData(t *testing.T) {
+	dir := t.TempDir()
+	db := NewDatabaseAt("test", dir)
+	p := NewSQLParser(db)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10), age INTEGER)")
+	mustExec(t, p, "CREATE INDEX idx_age ON t (age)")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 'a', 30), (2, 'b', 40)")
+	mustExec(t, p, "ALTER TABLE t DROP COLUMN age")
+
+	check := func(db *Database) {
+		t.Helper()
+		table := db.Tables["t"]
+		if table.hasColumn("age") || len(table.Columns) != 2 {
+			t.Fatalf("column metadata not removed: %+v", table.Columns)
+		}
+		if len(table.Indexes) != 0 {
+			t.Fatalf("index on dropped column remains: %+v", table.Indexes)
+		}
+		for i, row := range table.Rows {
+			if _, ok := row["age"]; ok || len(row) != 2 {
+				t.Fatalf("row %d still has dropped data: %v", i+1, row)
+			}
+		}
+	}
+	check(db)
+	mustFail(t, p, "SELECT age FROM t", "column 'age' does not exist")
+	assertValues(t, mustExec(t, p, "SHOW COLUMNS FROM t"), "name", "id", "name")
+
+	// 保存したファイルからも取り除かれ、同じ名前で追加し直しても古い値は戻らない
+	loaded, err := LoadDatabaseAt("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(loaded)
+	lp := NewSQLParser(loaded)
+	mustExec(t, lp, "ALTER TABLE t ADD COLUMN age INTEGER")
+	assertValues(t, mustExec(t, lp, "SELECT age FROM t ORDER BY id"), "age", "NULL", "NULL")
+}
+
+func TestSQLComments(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, s VARCHAR(20)) -- table for comments")
+	mustExec(t, p, "INSERT /* inline */ INTO t VALUES (1, 'a') -- trailing")
+	mustExec(t, p, "INSERT INTO t VALUES (2, '-- not a comment'), (3, '/* not */')")
+	assertValues(t, mustExec(t, p, "SELECT s FROM t ORDER BY id"), "s", "a", "-- not a comment", "/* not */")
+
+	// コメントは空白と同じく区切りになる
+	assertValues(t, mustExec(t, p, "SELECT id--x\nFROM t WHERE id = 1"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id/**/FROM/* multi\nline */t WHERE id = 2"), "id", "2")
+	assertValues(t, mustExec(t, p, "SELECT 4 /* a */ / 2 AS q"), "q", "2")
+	assertValues(t, mustExec(t, p, "SELECT 10 - -1 AS q"), "q", "11")
+	mustFail(t, p, "SELECT id FROM t /* unterminated", "unterminated comment")
+	mustFail(t, p, "-- only a comment", "empty query")
+	mustFail(t, p, "/* only */", "empty query")
+
+	// スクリプトではコメント中の ; で文を区切らない
+	results, err := p.ParseAll("-- header\nINSERT INTO t VALUES (4, 'x'); /* multi\nline; */ INSERT INTO t VALUES (5, 'y;z'); -- end")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("ran %d statements, want 2", len(results))
+	}
+	assertValues(t, mustExec(t, p, "SELECT s FROM t WHERE id > 3 ORDER BY id"), "s", "x", "y;z")
+}
+
+func TestDoubledQuoteInLiteral(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE people (id INTEGER PRIMARY KEY, name VARCHAR(20))")
+	mustExec(t, p, "INSERT INTO people VALUES (1, 'O''Brien'), (2, ''''), (3, 'it''s ''quoted''')")
+
+	// '' は引用符1文字として保存・比較する
+	assertValues(t, mustExec(t, p, "SELECT name FROM people ORDER BY id"), "name", "O'Brien", "'", "it's 'quoted'")
+	assertValues(t, mustExec(t, p, "SELECT id FROM people WHERE name = 'O''Brien'"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM people WHERE name = ?", "O'Brien"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM people WHERE name LIKE '%''%' ORDER BY id"), "id", "1", "2", "3")
+	assertValues(t, mustExec(t, p, "SELECT LENGTH(name) AS n FROM people WHERE id = 2"), "n", "1")
+
+	mustExec(t, p, "UPDATE people SET name = 'D''Arcy' WHERE name = 'O''Brien'")
+	assertValues(t, mustExec(t, p, "SELECT name FROM people WHERE id = 1"), "name", "D'Arcy")
+}
+
+func TestDistinctWithNulls(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, a VARCHAR(10), b INTEGER)")
+	mustExec(t, p, "INSERT INTO t VALUES (1, NULL, 1), (2, 'x', NULL), (3, NULL, 1), (4, 'NULL', NULL), (5, '', 2), (6, NULL, NULL), (7, 'x', NULL)")
+
+	// NULL同士は同じ値として1行にまとめ、文字列の'NULL'や空文字列とは区別する
+	result := mustExec(t, p, "SELECT DISTINCT a FROM t ORDER BY a")
+	if len(result.Rows) != 4 {
+		t.Fatalf("got %d distinct values, want 4: %v", len(result.Rows), result.Rows)
+	}
+	if result.Rows[3]["a"] != nil {
+		t.Fatalf("NULL should sort last, got %v", result.Rows)
+	}
+	assertValues(t, mustExec(t, p, "SELECT DISTINCT a FROM t WHERE a IS NOT NULL ORDER BY a"), "a", "", "NULL", "x")
+
+	// 複数カラムでは、NULLの位置も含めて一致する行だけをまとめる
+	result = mustExec(t, p, "SELECT DISTINCT a, b FROM t ORDER BY id")
+	assertValues(t, result, "a", "NULL", "x", "NULL", "", "NULL")
+	assertValues(t, result, "b", "1", "NULL", "NULL", "2", "NULL")
+	if result.Rows[2]["a"] != "NULL" || result.Rows[4]["a"] != nil {
+		t.Fatalf("string 'NULL' and NULL were merged: %v", result.Rows)
+	}
+	assertValues(t, mustExec(t, p, "SELECT COUNT(*) FROM t WHERE b IS NULL"), "COUNT(*)", "4")
+}
+
+func TestOrderByNulls(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, v INTEGER, g VARCHAR(5))")
+	mustExec(t, p, "INSERT INTO t VALUES (1, 20, 'a'), (2, NULL, 'b'), (3, 10, NULL), (4, NULL, 'a'), (5, 30, 'b')")
+
+	// NULLは最大値として扱う（昇順で末尾、降順で先頭）。NULL同士は元の順序を保つ
+	for query, want := range map[string][]string{
+		"SELECT id FROM t ORDER BY v":                    {"3", "1", "5", "2", "4"},
+		"SELECT id FROM t ORDER BY v ASC":                {"3", "1", "5", "2", "4"},
+		"SELECT id FROM t ORDER BY v DESC":               {"2", "4", "5", "1", "3"},
+		"SELECT id FROM t ORDER BY v NULLS FIRST":        {"2", "4", "3", "1", "5"},
+		"SELECT id FROM t ORDER BY v DESC NULLS LAST":    {"5", "1", "3", "2", "4"},
+		"SELECT id FROM t ORDER BY v ASC NULLS LAST":     {"3", "1", "5", "2", "4"},
+		"SELECT id FROM t ORDER BY v DESC NULLS FIRST":   {"2", "4", "5", "1", "3"},
+		"SELECT id FROM t ORDER BY g, v DESC":            {"4", "1", "2", "5", "3"},
+		"SELECT id FROM t ORDER BY g DESC, v NULLS LAST": {"3", "5", "2", "1", "4"},
+	} {
+		assertValues(t, mustExec(t, p, query), "id", want...)
+	}
+
+	// グループ化した結果の並べ替えも同じ規則に従う
+	result := mustExec(t, p, "SELECT g, COUNT(*) FROM t GROUP BY g ORDER BY g DESC")
+	assertValues(t, result, "COUNT(*)", "1", "2", "2")
+	if result.Rows[0]["g"] != nil {
+		t.Fatalf("NULL group should come first in DESC order: %v", result.Rows)
+	}
+	mustFail(t, p, "SELECT id FROM t ORDER BY v NULLS", "expected FIRST or LAST after NULLS")
+}
+
+func TestIsNull(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, v INTEGER, s VARCHAR(5))")
+	mustExec(t, p, "INSERT INTO t VALUES (1, NULL, 'a'), (2, 0, NULL), (3, 5, ''), (4, NULL, NULL)")
+
+	// 0や空文字列はNULLではない
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v IS NULL ORDER BY id"), "id", "1", "4")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v IS NOT NULL ORDER BY id"), "id", "2", "3")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE s is null ORDER BY id"), "id", "2", "4")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE s is not null ORDER BY id"), "id", "1", "3")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v IS NULL AND s IS NOT NULL"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE UPPER(s) IS NULL ORDER BY id"), "id", "2", "4")
+	// = NULL / != NULL はどの行にも一致しない
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v = NULL"), "id")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE v != NULL"), "id")
+
+	mustFail(t, p, "SELECT id FROM t WHERE v IS", "incomplete WHERE condition")
+	mustFail(t, p, "SELECT id FROM t WHERE v IS NOT", "expected NULL after IS NOT")
+	mustFail(t, p, "SELECT id FROM t WHERE v IS 5", "expected NULL after IS near '5'")
+	mustFail(t, p, "SELECT id FROM t WHERE v IS NULL extra", "unexpected token in SELECT: extra")
+
+	mustExec(t, p, "UPDATE t SET v = 9 WHERE v IS NULL AND s IS NULL")
+	mustExec(t, p, "DELETE FROM t WHERE v IS NULL")
+	assertValues(t, mustExec(t, p, "SELECT v FROM t ORDER BY id"), "v", "0", "5", "9")
+}
+
+func TestNumericLiterals(t *testing.T) {
+	_, p := newTestDB(t)
+	mustExec(t, p, "CREATE TABLE t (id INTEGER, i INTEGER, f FLOAT, d DECIMAL(8,3))")
+	mustExec(t, p, "INSERT INTO t VALUES (1, -5, -2.5, -1.25)")
+	mustExec(t, p, "INSERT INTO t VALUES (2, +3, .5, 1e2)")
+	mustExec(t, p, "INSERT INTO t VALUES (3, 1e3, 1.5E-3, -2.)")
+	mustExec(t, p, "INSERT INTO t VALUES (4, 2.7, 2E+2, 0.0005)")
+
+	result := mustExec(t, p, "SELECT * FROM t ORDER BY id")
+	assertValues(t, result, "i", "-5", "3", "1000", "2")
+	assertValues(t, result, "f", "-2.5", "0.5", "0.0015", "200")
+	assertValues(t, result, "d", "-1.250", "100.000", "-2.000", "0.001")
+
+	// WHEREと式の中でも同じ表記を使える
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE i < -1"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE f < 1e-2 ORDER BY id"), "id", "1", "3")
+	assertValues(t, mustExec(t, p, "SELECT id FROM t WHERE d = -1.25"), "id", "1")
+	assertValues(t, mustExec(t, p, "SELECT -2.5 * 2 AS x"), "x", "-5")
+	assertValues(t, mustExec(t, p, "SELECT 1e3 + 1 AS x"), "x", "1001")
+	assertValues(t, mustExec(t, p, "SELECT 10 - -1 AS x"), "x", "11")
+
+	// 数値として読めない表記と、INTEGERに収まらない値は拒否する
+	mustFail(t, p, "INSERT INTO t VALUES (5, 1e, 1, 1)", "column 'i'")
+	mustFail(t, p, "INSERT INTO t VALUES (5, 1.2.3, 1, 1)", "column 'i'")
+	mustFail(t, p, "INSERT INTO t VALUES (5, 99999999999999999999, 1, 1)", "column 'i': integer value 1e+20 out of range")
+	mustFail(t, p, "INSERT INTO t VALUES (5, -1e19, 1, 1)", "column 'i': integer value -1e+19 out of range")
+	mustFail(t, p, "UPDATE t SET i = 1e300", "integer value 1e+300 out of range")
+	assertValues(t, mustExec(t, p, "SELECT COUNT(*) FROM t"), "COUNT(*)", "4")
+}
+
+func TestDefaultValidation(t *testing.T) {
+	db, p := newTestDB(t)
+
+	// デフォルト値の変換エラーはカラム名を示し、strconvのエラーをそのまま返さない
+	for query, want := range map[string]string{
+		"CREATE TABLE a (h INTEGER DEFAULT 'abc')":    "invalid default for column 'h': invalid integer value 'abc'",
+		"CREATE TABLE a (h FLOAT DEFAULT 'abc')":      "invalid default for column 'h': invalid float value 'abc'",
+		"CREATE TABLE a (h BOOLEAN DEFAULT 'maybe')":  "invalid default for column 'h': invalid boolean value 'maybe'",
+		"CREATE TABLE a (h DATE DEFAULT '2024-13-1')": "invalid default for column 'h': invalid date value '2024-13-1'",
+	} {
+		_, err := p.Exec(query)
+		if err == nil || !strings.Contains(err.Error(), want) || strings.Contains(err.Error(), "strconv") {
+			t.Errorf("%s: error = %v, want %q", query, err, want)
+		}
+	}
+
+	// デフォルト値は自身のCHECK制約を満たす必要がある（DEFAULTとCHECKの順序によらない）
+	mustFail(t, p, "CREATE TABLE a (h INTEGER CHECK (h > 10) DEFAULT 1)", "default value 1 for column 'h' violates CHECK constraint: h > 10")
+	mustFail(t, p, "CREATE TABLE a (h INTEGER DEFAULT 1 CHECK (h > 10))", "default value 1 for column 'h' violates CHECK constraint: h > 10")
+	mustFail(t, p, "CREATE TABLE a (s VARCHAR(5) CHECK (LENGTH(s) > 2) DEFAULT 'ab')", "default value 'ab' for column 's' violates CHECK constraint: LENGTH(s) > 2")
+	if _, exists := db.Tables["a"]; exists {
+		t.Fatal("table with an invalid default should not be created")
+	}
+
+	mustExec(t, p, "CREATE TABLE b (id INTEGER, h INTEGER CHECK (h > 10) DEFAULT 11)")
+	mustFail(t, p, "ALTER TABLE b ADD COLUMN g INTEGER CHECK (g < 0) DEFAULT 5", "default value 5 for column 'g' violates CHECK constraint: g < 0")
+	mustFail(t, p, "ALTER TABLE b MODIFY COLUMN h INTEGER CHECK (h > 20) DEFAULT 11", "default value 11 for column 'h' violates CHECK constraint: h > 20")
+	mustExec(t, p, "INSERT INTO b (id) VALUES (1)")
+	assertValues(t, mustExec(t, p, "SELECT h FROM b"), "h", "11")
+	mustFail(t, p, "INSERT INTO b VALUES (2, 'zz')", "column 'h': invalid integer value 'zz'")
+}